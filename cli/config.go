@@ -15,6 +15,7 @@ const (
 	jobRun        = "job-run"
 	jobServiceRun = "job-service-run"
 	jobLocal      = "job-local"
+	calendar      = "calendar"
 )
 
 // Config contains the configuration
@@ -23,15 +24,21 @@ type Config struct {
 		middlewares.SlackConfig `mapstructure:",squash"`
 		middlewares.SaveConfig  `mapstructure:",squash"`
 		middlewares.MailConfig  `mapstructure:",squash"`
+		InstanceID              string `gcfg:"instance-id" mapstructure:"instance-id"`
+		OrphanPolicy            string `gcfg:"orphan-policy" mapstructure:"orphan-policy" default:"reattach"`
 	}
 	ExecJobs    map[string]*ExecJobConfig    `gcfg:"job-exec" mapstructure:"job-exec,squash"`
 	RunJobs     map[string]*RunJobConfig     `gcfg:"job-run" mapstructure:"job-run,squash"`
 	ServiceJobs map[string]*RunServiceConfig `gcfg:"job-service-run" mapstructure:"job-service-run,squash"`
 	LocalJobs   map[string]*LocalJobConfig   `gcfg:"job-local" mapstructure:"job-local,squash"`
+	Calendars   map[string]*core.Calendar    `gcfg:"calendar" mapstructure:"calendar,squash"`
 
 	sh            *core.Scheduler
 	dockerHandler *DockerHandler
 	logger        core.Logger
+	// labelCalendars are the names of the calendars defined by docker labels,
+	// removed when their labels disappear
+	labelCalendars map[string]bool
 }
 
 func NewConfig(logger core.Logger) *Config {
@@ -41,6 +48,8 @@ func NewConfig(logger core.Logger) *Config {
 	c.RunJobs = make(map[string]*RunJobConfig)
 	c.ServiceJobs = make(map[string]*RunServiceConfig)
 	c.LocalJobs = make(map[string]*LocalJobConfig)
+	c.Calendars = make(map[string]*core.Calendar)
+	c.labelCalendars = make(map[string]bool)
 	c.logger = logger
 	defaults.SetDefaults(c)
 	return c
@@ -79,18 +88,49 @@ func (c *Config) InitializeApp() error {
 			return err
 		}
 
+		fileCalendars := make(map[string]bool, len(c.Calendars))
+		for name := range c.Calendars {
+			fileCalendars[name] = true
+		}
+
 		if err := c.buildFromDockerLabels(dockerLabels); err != nil {
 			return err
 		}
 
+		for name := range c.Calendars {
+			if !fileCalendars[name] {
+				c.labelCalendars[name] = true
+			}
+		}
+
 		// Initialize middlewares again after reading the labels
 		c.buildSchedulerMiddlewares(c.sh)
 	}
 
+	for name, cal := range c.Calendars {
+		c.sh.SetCalendar(name, cal)
+	}
+
+	if c.Global.InstanceID != "" {
+		c.sh.InstanceID = c.Global.InstanceID
+	}
+
+	reconciler := &core.Reconciler{
+		Client:     c.dockerHandler.GetInternalDockerClient(),
+		Logger:     c.logger,
+		InstanceID: c.sh.InstanceID,
+		Policy:     c.Global.OrphanPolicy,
+	}
+
+	if err := reconciler.Run(); err != nil {
+		return err
+	}
+
 	for name, j := range c.ExecJobs {
 		defaults.SetDefaults(j)
 		j.Client = c.dockerHandler.GetInternalDockerClient()
 		j.Name = name
+		j.SetType(jobExec)
 		j.buildMiddlewares()
 		c.sh.AddJob(j)
 	}
@@ -99,6 +139,7 @@ func (c *Config) InitializeApp() error {
 		defaults.SetDefaults(j)
 		j.Client = c.dockerHandler.GetInternalDockerClient()
 		j.Name = name
+		j.SetType(jobRun)
 		j.buildMiddlewares()
 		c.sh.AddJob(j)
 	}
@@ -106,6 +147,7 @@ func (c *Config) InitializeApp() error {
 	for name, j := range c.LocalJobs {
 		defaults.SetDefaults(j)
 		j.Name = name
+		j.SetType(jobLocal)
 		j.buildMiddlewares()
 		c.sh.AddJob(j)
 	}
@@ -113,6 +155,7 @@ func (c *Config) InitializeApp() error {
 	for name, j := range c.ServiceJobs {
 		defaults.SetDefaults(j)
 		j.Name = name
+		j.SetType(jobServiceRun)
 		j.Client = c.dockerHandler.GetInternalDockerClient()
 		j.buildMiddlewares()
 		c.sh.AddJob(j)
@@ -136,6 +179,22 @@ func (c *Config) dockerLabelsUpdate(labels map[string]map[string]string) {
 	var parsedLabelConfig Config
 	parsedLabelConfig.buildFromDockerLabels(labels)
 
+	// Calendars are replaced in place, jobs are looking for them on each run
+	for name := range c.labelCalendars {
+		if _, ok := parsedLabelConfig.Calendars[name]; !ok {
+			c.logger.Debugf("Calendar %s is not found, Removing", name)
+			c.sh.RemoveCalendar(name)
+			delete(c.Calendars, name)
+			delete(c.labelCalendars, name)
+		}
+	}
+
+	for name, cal := range parsedLabelConfig.Calendars {
+		c.sh.SetCalendar(name, cal)
+		c.Calendars[name] = cal
+		c.labelCalendars[name] = true
+	}
+
 	// Calculate the delta execJobs
 	for name, j := range c.ExecJobs {
 		found := false
@@ -149,6 +208,7 @@ func (c *Config) dockerLabelsUpdate(labels map[string]map[string]string) {
 				defaults.SetDefaults(newJob)
 				newJob.Client = c.dockerHandler.GetInternalDockerClient()
 				newJob.Name = newJobsName
+				newJob.SetType(jobExec)
 				if newJob.Hash() != j.Hash() {
 					c.logger.Debugf("Job %s has changed, restarting", name)
 					// Remove from the scheduler
@@ -183,6 +243,7 @@ func (c *Config) dockerLabelsUpdate(labels map[string]map[string]string) {
 			defaults.SetDefaults(newJob)
 			newJob.Client = c.dockerHandler.GetInternalDockerClient()
 			newJob.Name = newJobsName
+			newJob.SetType(jobExec)
 			newJob.buildMiddlewares()
 			c.sh.AddJob(newJob)
 			c.ExecJobs[newJobsName] = newJob
@@ -201,6 +262,7 @@ func (c *Config) dockerLabelsUpdate(labels map[string]map[string]string) {
 				defaults.SetDefaults(newJob)
 				newJob.Client = c.dockerHandler.GetInternalDockerClient()
 				newJob.Name = newJobsName
+				newJob.SetType(jobRun)
 				if newJob.Hash() != j.Hash() {
 					// Remove from the scheduler
 					c.sh.RemoveJob(j)
@@ -233,6 +295,7 @@ func (c *Config) dockerLabelsUpdate(labels map[string]map[string]string) {
 			defaults.SetDefaults(newJob)
 			newJob.Client = c.dockerHandler.GetInternalDockerClient()
 			newJob.Name = newJobsName
+			newJob.SetType(jobRun)
 			newJob.buildMiddlewares()
 			c.sh.AddJob(newJob)
 			c.RunJobs[newJobsName] = newJob
@@ -244,6 +307,7 @@ func (c *Config) dockerLabelsUpdate(labels map[string]map[string]string) {
 type ExecJobConfig struct {
 	core.ExecJob              `mapstructure:",squash"`
 	middlewares.OverlapConfig `mapstructure:",squash"`
+	middlewares.WindowConfig  `mapstructure:",squash"`
 	middlewares.SlackConfig   `mapstructure:",squash"`
 	middlewares.SaveConfig    `mapstructure:",squash"`
 	middlewares.MailConfig    `mapstructure:",squash"`
@@ -251,6 +315,7 @@ type ExecJobConfig struct {
 
 func (c *ExecJobConfig) buildMiddlewares() {
 	c.ExecJob.Use(middlewares.NewOverlap(&c.OverlapConfig))
+	c.ExecJob.Use(middlewares.NewWindow(&c.WindowConfig))
 	c.ExecJob.Use(middlewares.NewSlack(&c.SlackConfig))
 	c.ExecJob.Use(middlewares.NewSave(&c.SaveConfig))
 	c.ExecJob.Use(middlewares.NewMail(&c.MailConfig))
@@ -260,6 +325,7 @@ func (c *ExecJobConfig) buildMiddlewares() {
 type RunServiceConfig struct {
 	core.RunServiceJob        `mapstructure:",squash"`
 	middlewares.OverlapConfig `mapstructure:",squash"`
+	middlewares.WindowConfig  `mapstructure:",squash"`
 	middlewares.SlackConfig   `mapstructure:",squash"`
 	middlewares.SaveConfig    `mapstructure:",squash"`
 	middlewares.MailConfig    `mapstructure:",squash"`
@@ -268,6 +334,7 @@ type RunServiceConfig struct {
 type RunJobConfig struct {
 	core.RunJob               `mapstructure:",squash"`
 	middlewares.OverlapConfig `mapstructure:",squash"`
+	middlewares.WindowConfig  `mapstructure:",squash"`
 	middlewares.SlackConfig   `mapstructure:",squash"`
 	middlewares.SaveConfig    `mapstructure:",squash"`
 	middlewares.MailConfig    `mapstructure:",squash"`
@@ -275,6 +342,7 @@ type RunJobConfig struct {
 
 func (c *RunJobConfig) buildMiddlewares() {
 	c.RunJob.Use(middlewares.NewOverlap(&c.OverlapConfig))
+	c.RunJob.Use(middlewares.NewWindow(&c.WindowConfig))
 	c.RunJob.Use(middlewares.NewSlack(&c.SlackConfig))
 	c.RunJob.Use(middlewares.NewSave(&c.SaveConfig))
 	c.RunJob.Use(middlewares.NewMail(&c.MailConfig))
@@ -284,6 +352,7 @@ func (c *RunJobConfig) buildMiddlewares() {
 type LocalJobConfig struct {
 	core.LocalJob             `mapstructure:",squash"`
 	middlewares.OverlapConfig `mapstructure:",squash"`
+	middlewares.WindowConfig  `mapstructure:",squash"`
 	middlewares.SlackConfig   `mapstructure:",squash"`
 	middlewares.SaveConfig    `mapstructure:",squash"`
 	middlewares.MailConfig    `mapstructure:",squash"`
@@ -291,6 +360,7 @@ type LocalJobConfig struct {
 
 func (c *LocalJobConfig) buildMiddlewares() {
 	c.LocalJob.Use(middlewares.NewOverlap(&c.OverlapConfig))
+	c.LocalJob.Use(middlewares.NewWindow(&c.WindowConfig))
 	c.LocalJob.Use(middlewares.NewSlack(&c.SlackConfig))
 	c.LocalJob.Use(middlewares.NewSave(&c.SaveConfig))
 	c.LocalJob.Use(middlewares.NewMail(&c.MailConfig))
@@ -298,6 +368,7 @@ func (c *LocalJobConfig) buildMiddlewares() {
 
 func (c *RunServiceConfig) buildMiddlewares() {
 	c.RunServiceJob.Use(middlewares.NewOverlap(&c.OverlapConfig))
+	c.RunServiceJob.Use(middlewares.NewWindow(&c.WindowConfig))
 	c.RunServiceJob.Use(middlewares.NewSlack(&c.SlackConfig))
 	c.RunServiceJob.Use(middlewares.NewSave(&c.SaveConfig))
 	c.RunServiceJob.Use(middlewares.NewMail(&c.MailConfig))
@@ -47,6 +47,17 @@ func (s *SuiteConfig) TestBuildFromString(c *C) {
 	c.Assert(conf.JobsCount(), Equals, 5)
 }
 
+func (s *SuiteConfig) TestBuildFromStringGlobal(c *C) {
+	conf, err := BuildFromString(`
+		[global]
+		instance-id = foo
+  `, &TestLogger{})
+
+	c.Assert(err, IsNil)
+	c.Assert(conf.Global.InstanceID, Equals, "foo")
+	c.Assert(conf.Global.OrphanPolicy, Equals, core.OrphanReattach)
+}
+
 func (s *SuiteConfig) TestJobDefaultsSet(c *C) {
 	j := &RunJobConfig{}
 	j.Pull = "false"
@@ -78,6 +89,14 @@ func (s *SuiteConfig) TestExecJobBuild(c *C) {
 	c.Assert(j.Middlewares(), HasLen, 1)
 }
 
+func (s *SuiteConfig) TestExecJobBuildOverlapPolicy(c *C) {
+	j := &ExecJobConfig{}
+	j.OverlapConfig.OverlapPolicy = middlewares.OverlapQueue
+	j.buildMiddlewares()
+
+	c.Assert(j.Middlewares(), HasLen, 1)
+}
+
 func (s *SuiteConfig) TestConfigIni(c *C) {
 	testcases := []struct {
 		Ini            string
@@ -93,7 +112,7 @@ func (s *SuiteConfig) TestConfigIni(c *C) {
 			ExpectedConfig: Config{
 				ExecJobs: map[string]*ExecJobConfig{
 					"foo": {ExecJob: core.ExecJob{BareJob: core.BareJob{
-						Schedule: "@every 10s",
+						Schedule: []string{"@every 10s"},
 						Command:  `echo "foo"`,
 					}}},
 				},
@@ -110,7 +129,7 @@ func (s *SuiteConfig) TestConfigIni(c *C) {
 			ExpectedConfig: Config{
 				RunJobs: map[string]*RunJobConfig{
 					"foo": {RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "@every 10s",
+						Schedule: []string{"@every 10s"},
 					},
 						Environment: []string{"KEY1=value1", "KEY2=value2"},
 					}},
@@ -128,7 +147,7 @@ func (s *SuiteConfig) TestConfigIni(c *C) {
 			ExpectedConfig: Config{
 				RunJobs: map[string]*RunJobConfig{
 					"foo": {RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "@every 10s",
+						Schedule: []string{"@every 10s"},
 					},
 						VolumesFrom: []string{"volume1", "volume2"},
 					}},
@@ -136,6 +155,72 @@ func (s *SuiteConfig) TestConfigIni(c *C) {
 			},
 			Comment: "Test job-run with Env Variables",
 		},
+		{
+			Ini: `
+				[job-local "foo"]
+				schedule = @startup
+				run-on-start = true
+				jitter = 5m
+				`,
+			ExpectedConfig: Config{
+				LocalJobs: map[string]*LocalJobConfig{
+					"foo": {LocalJob: core.LocalJob{BareJob: core.BareJob{
+						Schedule:   []string{"@startup"},
+						RunOnStart: true,
+						Jitter:     "5m",
+					}}},
+				},
+			},
+			Comment: "Test job-local with run-on-start and jitter",
+		},
+		{
+			Ini: `
+				[calendar "holidays"]
+				date = 2026-12-25
+				date = 2026-12-31/2027-01-01
+				window = sat,sun
+
+				[job-local "foo"]
+				schedule = @daily
+				active-until = 2027-06-30
+				blackout = holidays
+				`,
+			ExpectedConfig: Config{
+				Calendars: map[string]*core.Calendar{
+					"holidays": {
+						Date:   []string{"2026-12-25", "2026-12-31/2027-01-01"},
+						Window: []string{"sat,sun"},
+					},
+				},
+				LocalJobs: map[string]*LocalJobConfig{
+					"foo": {
+						LocalJob: core.LocalJob{BareJob: core.BareJob{
+							Schedule: []string{"@daily"},
+						}},
+						WindowConfig: middlewares.WindowConfig{
+							ActiveUntil: "2027-06-30",
+							Blackout:    []string{"holidays"},
+						},
+					},
+				},
+			},
+			Comment: "Test calendar and job-local with blackout",
+		},
+		{
+			Ini: `
+				[job-exec "foo"]
+				schedule = 0 8 * * 1-5
+				schedule = 0 11 * * 6,0
+				`,
+			ExpectedConfig: Config{
+				ExecJobs: map[string]*ExecJobConfig{
+					"foo": {ExecJob: core.ExecJob{BareJob: core.BareJob{
+						Schedule: []string{"0 8 * * 1-5", "0 11 * * 6,0"},
+					}}},
+				},
+			},
+			Comment: "Test job-exec with multiple schedules",
+		},
 	}
 
 	for _, t := range testcases {
@@ -148,6 +233,36 @@ func (s *SuiteConfig) TestConfigIni(c *C) {
 	}
 }
 
+func (s *SuiteConfig) TestDockerLabelsUpdateCalendars(c *C) {
+	cfg := NewConfig(&TestLogger{})
+	cfg.sh = core.NewScheduler(&TestLogger{})
+
+	file := &core.Calendar{Window: []string{"sun"}}
+	cfg.Calendars["maintenance"] = file
+	cfg.sh.SetCalendar("maintenance", file)
+
+	cfg.dockerLabelsUpdate(map[string]map[string]string{
+		"ofelia": {
+			requiredLabel:                           "true",
+			serviceLabel:                            "true",
+			labelPrefix + ".calendar.holidays.date": "2026-12-25",
+		},
+	})
+
+	holidays, ok := cfg.sh.Calendar("holidays")
+	c.Assert(ok, Equals, true)
+	c.Assert(holidays.Date, DeepEquals, []string{"2026-12-25"})
+
+	// the calendars of the removed labels are removed, the ones of the
+	// config file are kept
+	cfg.dockerLabelsUpdate(map[string]map[string]string{})
+
+	_, ok = cfg.sh.Calendar("holidays")
+	c.Assert(ok, Equals, false)
+	_, ok = cfg.sh.Calendar("maintenance")
+	c.Assert(ok, Equals, true)
+}
+
 func (s *SuiteConfig) TestLabelsConfig(c *C) {
 	testcases := []struct {
 		Labels         map[string]map[string]string
@@ -227,19 +342,19 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			ExpectedConfig: Config{
 				LocalJobs: map[string]*LocalJobConfig{
 					"job1": &LocalJobConfig{LocalJob: core.LocalJob{BareJob: core.BareJob{
-						Schedule: "schedule1",
+						Schedule: []string{"schedule1"},
 						Command:  "command1",
 					}}},
 				},
 				RunJobs: map[string]*RunJobConfig{
 					"job2": &RunJobConfig{RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "schedule2",
+						Schedule: []string{"schedule2"},
 						Command:  "command2",
 					}}},
 				},
 				ServiceJobs: map[string]*RunServiceConfig{
 					"job3": &RunServiceConfig{RunServiceJob: core.RunServiceJob{BareJob: core.BareJob{
-						Schedule: "schedule3",
+						Schedule: []string{"schedule3"},
 						Command:  "command3",
 					}}},
 				},
@@ -263,12 +378,12 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			ExpectedConfig: Config{
 				ExecJobs: map[string]*ExecJobConfig{
 					"job1": &ExecJobConfig{ExecJob: core.ExecJob{BareJob: core.BareJob{
-						Schedule: "schedule1",
+						Schedule: []string{"schedule1"},
 						Command:  "command1",
 					}}},
 					"job2": &ExecJobConfig{ExecJob: core.ExecJob{
 						BareJob: core.BareJob{
-							Schedule: "schedule2",
+							Schedule: []string{"schedule2"},
 							Command:  "command2",
 						},
 						Container: "other",
@@ -277,6 +392,58 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			},
 			Comment: "Exec jobs from non-service container, saves container name to be able to exect to",
 		},
+		{
+			Labels: map[string]map[string]string{
+				"other": map[string]string{
+					requiredLabel: "true",
+					labelPrefix + "." + jobExec + ".job1.schedule":        "schedule1",
+					labelPrefix + "." + jobExec + ".job1.command":         "command1",
+					labelPrefix + "." + jobExec + ".job1.container-label": `["role=db"]`,
+					labelPrefix + "." + jobExec + ".job1.compose-project": "shop",
+					labelPrefix + "." + jobExec + ".job1.compose-service": "db",
+					labelPrefix + "." + jobExec + ".job1.env-file":        `["/etc/ofelia/secrets.env"]`,
+					labelPrefix + "." + jobExec + ".job1.env-from-host":   "AWS_SECRET_ACCESS_KEY",
+					labelPrefix + "." + jobExec + ".job1.privileged":      "true",
+				},
+			},
+			ExpectedConfig: Config{
+				ExecJobs: map[string]*ExecJobConfig{
+					"job1": &ExecJobConfig{ExecJob: core.ExecJob{
+						BareJob: core.BareJob{
+							Schedule: []string{"schedule1"},
+							Command:  "command1",
+						},
+						Container: "other",
+					}},
+				},
+			},
+			Comment: "Exec jobs from non-service container can't target other containers, be privileged nor read the host environment",
+		},
+		{
+			Labels: map[string]map[string]string{
+				"some": map[string]string{
+					requiredLabel: "true",
+					serviceLabel:  "true",
+					labelPrefix + "." + jobExec + ".job1.schedule":      "schedule1",
+					labelPrefix + "." + jobExec + ".job1.env-file":      `["/etc/ofelia/secrets.env"]`,
+					labelPrefix + "." + jobExec + ".job1.env-from-host": "AWS_SECRET_ACCESS_KEY",
+					labelPrefix + "." + jobExec + ".job1.privileged":    "true",
+				},
+			},
+			ExpectedConfig: Config{
+				ExecJobs: map[string]*ExecJobConfig{
+					"job1": &ExecJobConfig{ExecJob: core.ExecJob{
+						BareJob: core.BareJob{
+							Schedule: []string{"schedule1"},
+						},
+						EnvFile:     []string{"/etc/ofelia/secrets.env"},
+						EnvFromHost: []string{"AWS_SECRET_ACCESS_KEY"},
+						Privileged:  true,
+					}},
+				},
+			},
+			Comment: "Exec jobs from service container can read the host environment",
+		},
 		{
 			Labels: map[string]map[string]string{
 				"some": map[string]string{
@@ -290,7 +457,7 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			ExpectedConfig: Config{
 				ExecJobs: map[string]*ExecJobConfig{
 					"job1": &ExecJobConfig{ExecJob: core.ExecJob{BareJob: core.BareJob{
-						Schedule: "schedule1",
+						Schedule: []string{"schedule1"},
 						Command:  "command1",
 					}},
 						OverlapConfig: middlewares.OverlapConfig{NoOverlap: true},
@@ -315,14 +482,14 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			ExpectedConfig: Config{
 				RunJobs: map[string]*RunJobConfig{
 					"job1": {RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "schedule1",
+						Schedule: []string{"schedule1"},
 						Command:  "command1",
 					},
 						Volume: []string{"/test/tmp:/test/tmp:ro"},
 					},
 					},
 					"job2": {RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "schedule2",
+						Schedule: []string{"schedule2"},
 						Command:  "command2",
 					},
 						Volume: []string{"/test/tmp:/test/tmp:ro", "/test/tmp:/test/tmp:rw"},
@@ -348,14 +515,14 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			ExpectedConfig: Config{
 				RunJobs: map[string]*RunJobConfig{
 					"job1": {RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "schedule1",
+						Schedule: []string{"schedule1"},
 						Command:  "command1",
 					},
 						Environment: []string{"KEY1=value1"},
 					},
 					},
 					"job2": {RunJob: core.RunJob{BareJob: core.BareJob{
-						Schedule: "schedule2",
+						Schedule: []string{"schedule2"},
 						Command:  "command2",
 					},
 						Environment: []string{"KEY1=value1", "KEY2=value2"},
@@ -383,7 +550,7 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 					"job1": {
 						RunJob: core.RunJob{
 							BareJob: core.BareJob{
-								Schedule: "schedule1",
+								Schedule: []string{"schedule1"},
 								Command:  "command1",
 							},
 							VolumesFrom: []string{"test123"},
@@ -392,7 +559,7 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 					"job2": {
 						RunJob: core.RunJob{
 							BareJob: core.BareJob{
-								Schedule: "schedule2",
+								Schedule: []string{"schedule2"},
 								Command:  "command2",
 							},
 							VolumesFrom: []string{"test321", "test456"},
@@ -402,6 +569,87 @@ func (s *SuiteConfig) TestLabelsConfig(c *C) {
 			},
 			Comment: "Test run job with volumes-from",
 		},
+		{
+			Labels: map[string]map[string]string{
+				"some": {
+					requiredLabel: "true",
+					serviceLabel:  "true",
+					labelPrefix + "." + calendar + ".freeze.date":    `["2026-12-24", "2026-12-31"]`,
+					labelPrefix + "." + jobRun + ".job1.schedule":    "schedule1",
+					labelPrefix + "." + jobRun + ".job1.blackout":    "freeze",
+					labelPrefix + "." + jobRun + ".job1.active-from": "2026-01-01",
+				},
+			},
+			ExpectedConfig: Config{
+				Calendars: map[string]*core.Calendar{
+					"freeze": {Date: []string{"2026-12-24", "2026-12-31"}},
+				},
+				RunJobs: map[string]*RunJobConfig{
+					"job1": {
+						RunJob: core.RunJob{
+							BareJob: core.BareJob{
+								Schedule: []string{"schedule1"},
+							},
+						},
+						WindowConfig: middlewares.WindowConfig{
+							ActiveFrom: "2026-01-01",
+							Blackout:   []string{"freeze"},
+						},
+					},
+				},
+			},
+			Comment: "Test calendar and run job with blackout",
+		},
+		{
+			Labels: map[string]map[string]string{
+				"some": {
+					requiredLabel: "true",
+					serviceLabel:  "true",
+					labelPrefix + "." + jobLocal + ".job1.schedule": `["0 8 * * 1-5", "0 11 * * 6,0"]`,
+					labelPrefix + "." + jobLocal + ".job1.command":  "command1",
+				},
+			},
+			ExpectedConfig: Config{
+				LocalJobs: map[string]*LocalJobConfig{
+					"job1": {LocalJob: core.LocalJob{BareJob: core.BareJob{
+						Schedule: []string{"0 8 * * 1-5", "0 11 * * 6,0"},
+						Command:  "command1",
+					}}},
+				},
+			},
+			Comment: "Test local job with multiple schedules",
+		},
+		{
+			Labels: map[string]map[string]string{
+				"some": {
+					requiredLabel: "true",
+					serviceLabel:  "true",
+					labelPrefix + "." + jobRun + ".job1.schedule":   "schedule1",
+					labelPrefix + "." + jobRun + ".job1.memory":     "512m",
+					labelPrefix + "." + jobRun + ".job1.read-only":  "true",
+					labelPrefix + "." + jobRun + ".job1.pids-limit": "100",
+					labelPrefix + "." + jobRun + ".job1.cap-drop":   `["ALL"]`,
+					labelPrefix + "." + jobRun + ".job1.tmpfs":      "/tmp",
+					labelPrefix + "." + jobRun + ".job1.network":    `["db", "name=storage,alias=etl"]`,
+				},
+			},
+			ExpectedConfig: Config{
+				RunJobs: map[string]*RunJobConfig{
+					"job1": {RunJob: core.RunJob{
+						BareJob: core.BareJob{
+							Schedule: []string{"schedule1"},
+						},
+						Memory:    "512m",
+						ReadOnly:  true,
+						PidsLimit: 100,
+						CapDrop:   []string{"ALL"},
+						Tmpfs:     []string{"/tmp"},
+						Network:   []string{"db", "name=storage,alias=etl"},
+					}},
+				},
+			},
+			Comment: "Test run job with container options",
+		},
 	}
 
 	for _, t := range testcases {
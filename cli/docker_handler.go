@@ -177,11 +177,25 @@ func parseFilter(filter string) (key, value string, err error) {
 	return parts[0], parts[1], nil
 }
 
+// serviceOnlyExecParams are the job-exec params only accepted from the labels
+// of the service container, a job-exec of another container always runs in
+// that container, without privileges it wasn't created with, and can't read
+// the files nor the environment of ofelia
+var serviceOnlyExecParams = map[string]bool{
+	"privileged":      true,
+	"container-label": true,
+	"compose-project": true,
+	"compose-service": true,
+	"env-file":        true,
+	"env-from-host":   true,
+}
+
 func (c *Config) buildFromDockerLabels(labels map[string]map[string]string) error {
 	execJobs := make(map[string]map[string]interface{})
 	localJobs := make(map[string]map[string]interface{})
 	runJobs := make(map[string]map[string]interface{})
 	serviceJobs := make(map[string]map[string]interface{})
+	calendars := make(map[string]map[string]interface{})
 	globalConfigs := make(map[string]interface{})
 
 	for c, l := range labels {
@@ -207,6 +221,10 @@ func (c *Config) buildFromDockerLabels(labels map[string]map[string]string) erro
 			jobType, jobName, jopParam := parts[1], parts[2], parts[3]
 			switch {
 			case jobType == jobExec: // only job exec can be provided on the non-service container
+				if !isServiceContainer && serviceOnlyExecParams[strings.ToLower(jopParam)] {
+					continue
+				}
+
 				if _, ok := execJobs[jobName]; !ok {
 					execJobs[jobName] = make(map[string]interface{})
 				}
@@ -232,6 +250,11 @@ func (c *Config) buildFromDockerLabels(labels map[string]map[string]string) erro
 					runJobs[jobName] = make(map[string]interface{})
 				}
 				setJobParam(runJobs[jobName], jopParam, v)
+			case jobType == calendar && isServiceContainer:
+				if _, ok := calendars[jobName]; !ok {
+					calendars[jobName] = make(map[string]interface{})
+				}
+				setJobParam(calendars[jobName], jopParam, v)
 			default:
 				// TODO: warn about unknown parameter
 			}
@@ -268,12 +291,21 @@ func (c *Config) buildFromDockerLabels(labels map[string]map[string]string) erro
 		}
 	}
 
+	if len(calendars) > 0 {
+		if err := mapstructure.WeakDecode(calendars, &c.Calendars); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func setJobParam(params map[string]interface{}, paramName, paramVal string) {
 	switch strings.ToLower(paramName) {
-	case "volume", "environment", "volumes-from":
+	case "schedule", "volume", "environment", "volumes-from", "blackout", "date", "window", "ical",
+		"label", "cap-add", "cap-drop", "tmpfs", "device", "security-opt", "ulimit", "add-host", "dns", "log-opt",
+		"network", "publish", "upload", "upload-content", "artifacts", "build-arg", "container-label",
+		"mount", "secret", "config", "constraint", "env-file", "env-from-host":
 		arr := []string{} // allow providing JSON arr of volume mounts
 		if err := json.Unmarshal([]byte(paramVal), &arr); err == nil {
 			params[paramName] = arr
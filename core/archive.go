@@ -0,0 +1,118 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Artifact is a file or directory copied out of the container of a job once
+// it exits, as a tar archive
+type Artifact struct {
+	Path    string
+	Archive []byte `json:"-"`
+}
+
+// buildUploadArchive builds a tar archive, to be extracted at the root of a
+// container, with the given host files or directories, `host:container`, and
+// inline contents, `container=content`
+func buildUploadArchive(uploads, contents []string) (io.Reader, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for _, u := range uploads {
+		src, dst, ok := strings.Cut(u, ":")
+		if !ok || src == "" || !path.IsAbs(dst) {
+			return nil, fmt.Errorf("invalid upload %q, expected format is /host/path:/container/path", u)
+		}
+
+		if err := addToArchive(tw, src, dst); err != nil {
+			return nil, fmt.Errorf("error uploading %q: %w", src, err)
+		}
+	}
+
+	for _, c := range contents {
+		dst, content, ok := strings.Cut(c, "=")
+		if !ok || !path.IsAbs(dst) {
+			return nil, fmt.Errorf("invalid upload content %q, expected format is /container/path=content", c)
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     archiveName(dst),
+			Mode:     0o644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return nil, err
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
+
+func addToArchive(tw *tar.Writer, src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return fmt.Errorf("%q is not a regular file nor a directory", p)
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = archiveName(path.Join(dst, filepath.ToSlash(rel)))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+func archiveName(p string) string {
+	return strings.TrimPrefix(path.Clean(p), "/")
+}
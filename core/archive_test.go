@@ -0,0 +1,62 @@
+package core
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type SuiteArchive struct{}
+
+var _ = Suite(&SuiteArchive{})
+
+func (s *SuiteArchive) TestBuildUploadArchive(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "report.sql"), []byte("SELECT 1"), 0o600), IsNil)
+	c.Assert(os.Mkdir(filepath.Join(dir, "conf"), 0o755), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "conf", "app.yml"), []byte("debug: true"), 0o644), IsNil)
+
+	r, err := buildUploadArchive(
+		[]string{filepath.Join(dir, "report.sql") + ":/app/report.sql", filepath.Join(dir, "conf") + ":/etc/app"},
+		[]string{"/app/token=foo=bar"},
+	)
+	c.Assert(err, IsNil)
+
+	files := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+		content, err := io.ReadAll(tr)
+		c.Assert(err, IsNil)
+		files[hdr.Name] = string(content)
+	}
+
+	c.Assert(files, DeepEquals, map[string]string{
+		"app/report.sql":  "SELECT 1",
+		"etc/app/":        "",
+		"etc/app/app.yml": "debug: true",
+		"app/token":       "foo=bar",
+	})
+}
+
+func (s *SuiteArchive) TestBuildUploadArchiveInvalid(c *C) {
+	_, err := buildUploadArchive([]string{"/tmp"}, nil)
+	c.Assert(err, NotNil)
+
+	_, err = buildUploadArchive([]string{"/tmp:relative"}, nil)
+	c.Assert(err, NotNil)
+
+	_, err = buildUploadArchive([]string{"/missing/file:/foo"}, nil)
+	c.Assert(err, NotNil)
+
+	_, err = buildUploadArchive(nil, []string{"relative=foo"})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,281 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// dockerHubServer is the server address used by the docker CLI to store
+	// the credentials of Docker Hub
+	dockerHubServer = "https://index.docker.io/v1/"
+	// helperNotFound is the error message of a credential helper without
+	// credentials for the given server
+	helperNotFound = "credentials not found in native keychain"
+	// helperTokenUser is the username returned by a credential helper when
+	// the secret is an identity token
+	helperTokenUser = "<token>"
+)
+
+// credentials are the registry credentials of the docker config file of the
+// user running ofelia, and of the registry-auth files of the jobs, by path
+var credentials = struct {
+	sync.Mutex
+	files map[string]*credentialFile
+}{files: make(map[string]*credentialFile)}
+
+// dockerConfigPath returns the path of the docker config file, as the docker
+// CLI does, using $DOCKER_CONFIG or $HOME/.docker
+func dockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// legacyDockerConfigPath returns the path of the docker config file of the
+// old docker versions, $HOME/.dockercfg
+func legacyDockerConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".dockercfg")
+}
+
+// lookupAuth returns the credentials for the given registry, an empty
+// registry is Docker Hub. When file is empty the docker config file is used,
+// falling back to the legacy one when it has no credentials for the registry.
+func lookupAuth(file, registry string) (docker.AuthConfiguration, error) {
+	if file != "" {
+		return getCredentialFile(file, false).lookup(registry)
+	}
+
+	auth, err := getCredentialFile(dockerConfigPath(), false).lookup(registry)
+	if err != nil || auth != (docker.AuthConfiguration{}) {
+		return auth, err
+	}
+
+	if legacy := legacyDockerConfigPath(); legacy != "" {
+		return getCredentialFile(legacy, true).lookup(registry)
+	}
+
+	return auth, nil
+}
+
+func getCredentialFile(path string, legacy bool) *credentialFile {
+	credentials.Lock()
+	defer credentials.Unlock()
+
+	c, ok := credentials.files[path]
+	if !ok {
+		c = &credentialFile{path: path, legacy: legacy}
+		credentials.files[path] = c
+	}
+
+	return c
+}
+
+// credentialFile is a docker config file, it is reloaded when it changes.
+// The legacy files only have the credentials, as the auths of config.json.
+type credentialFile struct {
+	sync.Mutex
+	path    string
+	legacy  bool
+	modTime time.Time
+	size    int64
+	config  dockerConfigFile
+}
+
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	Email         string `json:"email"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+func (c *credentialFile) lookup(registry string) (docker.AuthConfiguration, error) {
+	config, err := c.load()
+	if err != nil {
+		return docker.AuthConfiguration{}, err
+	}
+
+	server := registry
+	if server == "" {
+		server = dockerHubServer
+	}
+
+	helper := config.CredsStore
+	if h, ok := config.CredHelpers[registryHost(server)]; ok {
+		helper = h
+	}
+
+	if helper != "" {
+		auth, found, err := runCredentialHelper(helper, server)
+		if err != nil {
+			return auth, err
+		}
+
+		if found {
+			return auth, nil
+		}
+	}
+
+	for key, a := range config.Auths {
+		if registryHost(key) != registryHost(server) {
+			continue
+		}
+
+		return a.authConfiguration(key)
+	}
+
+	return docker.AuthConfiguration{}, nil
+}
+
+// load returns the content of the file, reading it again if it has changed
+// since the last time, a missing file has no credentials
+func (c *credentialFile) load() (dockerConfigFile, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	fi, err := os.Stat(c.path)
+	if os.IsNotExist(err) {
+		c.config, c.modTime, c.size = dockerConfigFile{}, time.Time{}, 0
+		return c.config, nil
+	}
+
+	if err != nil {
+		return c.config, fmt.Errorf("error reading registry credentials %q: %w", c.path, err)
+	}
+
+	if fi.ModTime().Equal(c.modTime) && fi.Size() == c.size {
+		return c.config, nil
+	}
+
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		return c.config, fmt.Errorf("error reading registry credentials %q: %w", c.path, err)
+	}
+
+	var config dockerConfigFile
+	if c.legacy {
+		err = json.Unmarshal(content, &config.Auths)
+	} else {
+		err = json.Unmarshal(content, &config)
+	}
+
+	if err != nil {
+		return c.config, fmt.Errorf("error reading registry credentials %q: %w", c.path, err)
+	}
+
+	c.config, c.modTime, c.size = config, fi.ModTime(), fi.Size()
+	return c.config, nil
+}
+
+func (a dockerConfigAuth) authConfiguration(server string) (docker.AuthConfiguration, error) {
+	auth := docker.AuthConfiguration{
+		Username:      a.Username,
+		Password:      a.Password,
+		Email:         a.Email,
+		ServerAddress: server,
+		IdentityToken: a.IdentityToken,
+		RegistryToken: a.RegistryToken,
+	}
+
+	if a.Auth == "" {
+		return auth, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+	if err != nil {
+		return auth, fmt.Errorf("invalid registry credentials for %q: %w", server, err)
+	}
+
+	var ok bool
+	auth.Username, auth.Password, ok = strings.Cut(string(decoded), ":")
+	if !ok {
+		return auth, fmt.Errorf("invalid registry credentials for %q", server)
+	}
+
+	return auth, nil
+}
+
+// runCredentialHelper gets the credentials of the given server using the
+// credential helper protocol, running `docker-credential-<helper> get`
+func runCredentialHelper(helper, server string) (docker.AuthConfiguration, bool, error) {
+	var auth docker.AuthConfiguration
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		output := strings.TrimSpace(stdout.String() + stderr.String())
+		if strings.Contains(output, helperNotFound) {
+			return auth, false, nil
+		}
+
+		return auth, false, fmt.Errorf("error running credential helper %q: %s: %s", helper, err, output)
+	}
+
+	var c struct {
+		ServerURL string
+		Username  string
+		Secret    string
+	}
+
+	if err := json.Unmarshal(stdout.Bytes(), &c); err != nil {
+		return auth, false, fmt.Errorf("invalid output of credential helper %q: %w", helper, err)
+	}
+
+	auth.ServerAddress = server
+	if c.Username == helperTokenUser {
+		auth.IdentityToken = c.Secret
+	} else {
+		auth.Username, auth.Password = c.Username, c.Secret
+	}
+
+	return auth, true, nil
+}
+
+// registryHost returns the host of a registry address, which can be a URL,
+// eg.: `https://index.docker.io/v1/` is `index.docker.io`. Docker Hub
+// addresses are normalized to `index.docker.io`.
+func registryHost(address string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+
+	switch host {
+	case "", "docker.io", "registry-1.docker.io":
+		return "index.docker.io"
+	}
+
+	return host
+}
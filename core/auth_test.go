@@ -0,0 +1,143 @@
+package core
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+
+	docker "github.com/fsouza/go-dockerclient"
+	. "gopkg.in/check.v1"
+)
+
+type SuiteAuth struct {
+	dir  string
+	path string
+}
+
+var _ = Suite(&SuiteAuth{})
+
+func (s *SuiteAuth) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+
+	// fake credential helper, it knows the credentials of `helper.io` and
+	// an identity token for `token.io`
+	helper := `#!/bin/sh
+read server
+case "$server" in
+helper.io) echo '{"ServerURL":"helper.io","Username":"foo","Secret":"bar"}' ;;
+token.io) echo '{"ServerURL":"token.io","Username":"<token>","Secret":"qux"}' ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`
+	err := os.WriteFile(filepath.Join(s.dir, "docker-credential-test"), []byte(helper), 0o755)
+	c.Assert(err, IsNil)
+
+	s.path = os.Getenv("PATH")
+	os.Setenv("PATH", s.dir+string(os.PathListSeparator)+s.path)
+}
+
+func (s *SuiteAuth) TearDownTest(c *C) {
+	os.Setenv("PATH", s.path)
+}
+
+func (s *SuiteAuth) TestLookupAuth(c *C) {
+	file := s.writeConfig(c, "config.json", `{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "`+basicAuth("hub", "secret")+`"},
+			"https://quay.io": {"auth": "`+basicAuth("quay", "secret")+`"},
+			"helper.io": {"auth": "`+basicAuth("ignored", "ignored")+`"}
+		},
+		"credHelpers": {"helper.io": "test", "token.io": "test", "quay.io": "test"}
+	}`)
+
+	testCases := []struct {
+		registry string
+		expected docker.AuthConfiguration
+	}{
+		{"", docker.AuthConfiguration{Username: "hub", Password: "secret", ServerAddress: "https://index.docker.io/v1/"}},
+		{"quay.io", docker.AuthConfiguration{Username: "quay", Password: "secret", ServerAddress: "https://quay.io"}},
+		{"helper.io", docker.AuthConfiguration{Username: "foo", Password: "bar", ServerAddress: "helper.io"}},
+		{"token.io", docker.AuthConfiguration{IdentityToken: "qux", ServerAddress: "token.io"}},
+		{"unknown.io", docker.AuthConfiguration{}},
+	}
+
+	for _, t := range testCases {
+		auth, err := lookupAuth(file, t.registry)
+		c.Assert(err, IsNil)
+		c.Assert(auth, DeepEquals, t.expected, Commentf("registry %q", t.registry))
+	}
+}
+
+func (s *SuiteAuth) TestLookupAuthCredsStore(c *C) {
+	file := s.writeConfig(c, "config.json", `{"credsStore": "test"}`)
+
+	auth, err := lookupAuth(file, "helper.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth.Username, Equals, "foo")
+
+	_, err = lookupAuth(s.writeConfig(c, "other.json", `{"credsStore": "missing"}`), "helper.io")
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteAuth) TestLookupAuthReload(c *C) {
+	file := s.writeConfig(c, "config.json", `{"auths": {"foo.io": {"username": "foo"}}}`)
+
+	auth, err := lookupAuth(file, "foo.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth.Username, Equals, "foo")
+
+	s.writeConfig(c, "config.json", `{"auths": {"foo.io": {"username": "qux", "password": "bar"}}}`)
+
+	auth, err = lookupAuth(file, "foo.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth.Username, Equals, "qux")
+}
+
+func (s *SuiteAuth) TestLookupAuthMissingFile(c *C) {
+	auth, err := lookupAuth(filepath.Join(s.dir, "missing.json"), "foo.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth, DeepEquals, docker.AuthConfiguration{})
+}
+
+func (s *SuiteAuth) TestLookupAuthDefaultFile(c *C) {
+	os.Setenv("DOCKER_CONFIG", s.dir)
+	defer os.Unsetenv("DOCKER_CONFIG")
+
+	s.writeConfig(c, "config.json", `{"auths": {"foo.io": {"username": "foo"}}}`)
+
+	auth, err := lookupAuth("", "foo.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth.Username, Equals, "foo")
+}
+
+func (s *SuiteAuth) TestLookupAuthLegacyFile(c *C) {
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", s.dir)
+	defer os.Setenv("HOME", home)
+
+	s.writeConfig(c, ".dockercfg", `{"foo.io": {"auth": "`+basicAuth("foo", "bar")+`", "email": "foo@foo.io"}}`)
+
+	auth, err := lookupAuth("", "foo.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth, DeepEquals, docker.AuthConfiguration{
+		Username: "foo", Password: "bar", Email: "foo@foo.io", ServerAddress: "foo.io",
+	})
+
+	// the credentials of config.json take precedence
+	c.Assert(os.Mkdir(filepath.Join(s.dir, ".docker"), 0o700), IsNil)
+	s.writeConfig(c, ".docker/config.json", `{"auths": {"foo.io": {"username": "qux"}}}`)
+
+	auth, err = lookupAuth("", "foo.io")
+	c.Assert(err, IsNil)
+	c.Assert(auth.Username, Equals, "qux")
+}
+
+func (s *SuiteAuth) writeConfig(c *C, name, content string) string {
+	file := filepath.Join(s.dir, name)
+	c.Assert(os.WriteFile(file, []byte(content), 0o600), IsNil)
+	return file
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
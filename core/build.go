@@ -0,0 +1,235 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+const (
+	// buildRepository is the repository of the built images when the job
+	// doesn't set an image name
+	buildRepository = "ofelia"
+	// buildTagLength is the length of the content hash used as tag
+	buildTagLength = 12
+)
+
+var invalidRepositoryChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// imageBuild is the build of the image of a job from a local context
+type imageBuild struct {
+	// Context is the directory sent to the docker daemon
+	Context string
+	// Dockerfile is the path of the Dockerfile, relative to the context
+	Dockerfile string
+	// Args are the build args as `name=value`
+	Args []string
+	// Platform is the platform the image is built for, eg.: linux/arm64
+	Platform string
+}
+
+// name returns the image name, the repository is the given image, or one
+// derived from the job name, and the tag the hash of the build, so the
+// image is only built when the context changes
+func (b *imageBuild) name(image, job string) (string, error) {
+	hash, err := b.hash()
+	if err != nil {
+		return "", err
+	}
+
+	repository, _ := docker.ParseRepositoryTag(image)
+	if repository == "" {
+		name := invalidRepositoryChars.ReplaceAllString(strings.ToLower(job), "-")
+		repository = buildRepository + "/" + strings.Trim(name, "-._")
+	}
+
+	return repository + ":" + hash[:buildTagLength], nil
+}
+
+func (b *imageBuild) dockerfile() string {
+	if b.Dockerfile == "" {
+		return "Dockerfile"
+	}
+
+	return b.Dockerfile
+}
+
+func (b *imageBuild) buildArgs() ([]docker.BuildArg, error) {
+	var args []docker.BuildArg
+	for _, a := range b.Args {
+		name, value, ok := strings.Cut(a, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid build arg %q, expected name=value", a)
+		}
+
+		args = append(args, docker.BuildArg{Name: name, Value: value})
+	}
+
+	return args, nil
+}
+
+// hash returns the hash of the files of the context, as sent to the docker
+// daemon following the .dockerignore file, the Dockerfile, the build args and
+// the platform
+func (b *imageBuild) hash() (string, error) {
+	excludes, err := b.excludes()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "dockerfile %s\n", b.dockerfile())
+	fmt.Fprintf(h, "platform %s\n", b.Platform)
+
+	args := append([]string{}, b.Args...)
+	sort.Strings(args)
+	for _, a := range args {
+		fmt.Fprintf(h, "arg %s\n", a)
+	}
+
+	dockerfile := filepath.Clean(b.dockerfile())
+	err = filepath.WalkDir(b.Context, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(b.Context, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		// the Dockerfile and the .dockerignore are always sent
+		if rel != dockerfile && rel != ".dockerignore" {
+			excluded, err := excludes.MatchesOrParentMatches(rel)
+			if err != nil {
+				return err
+			}
+
+			if excluded {
+				if d.IsDir() && !excludes.Exclusions() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(rel), info.Mode())
+		switch {
+		case info.Mode().IsRegular():
+			return hashFile(h, path)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(h, "%s\n", target)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error reading build context %q: %w", b.Context, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (b *imageBuild) excludes() (*patternmatcher.PatternMatcher, error) {
+	f, err := os.Open(filepath.Join(b.Context, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return patternmatcher.New(nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	patterns, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading .dockerignore: %w", err)
+	}
+
+	return patternmatcher.New(patterns)
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// ensureBuiltImage builds the image of the job, unless an image built from
+// the same context is found locally, and returns its name
+func ensureBuiltImage(ctx *Context, client *docker.Client, b *imageBuild, image string) (string, error) {
+	name, err := b.name(image, ctx.Job.GetName())
+	if err != nil {
+		return "", err
+	}
+
+	args, err := b.buildArgs()
+	if err != nil {
+		return "", err
+	}
+
+	// builds of the same image are serialized, like the pulls
+	built := pulls.get(name, b.Platform)
+	built.Lock()
+	defer built.Unlock()
+
+	if _, err := client.InspectImage(name); err == nil {
+		return name, nil
+	}
+
+	ctx.Log("Building image " + name)
+
+	output := newLineLogger(ctx, "build")
+	defer output.Flush()
+
+	err = client.BuildImage(docker.BuildImageOptions{
+		Context:             ctx.Execution.Context(),
+		Name:                name,
+		Dockerfile:          b.dockerfile(),
+		ContextDir:          b.Context,
+		BuildArgs:           args,
+		Platform:            b.Platform,
+		RmTmpContainer:      true,
+		ForceRmTmpContainer: true,
+		OutputStream:        output,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error building image %q: %w", name, err)
+	}
+
+	ctx.Log("Built image " + name)
+	return name, nil
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/fsouza/go-dockerclient/testing"
+	. "gopkg.in/check.v1"
+)
+
+type SuiteBuild struct {
+	server *testing.DockerServer
+	client *docker.Client
+	builds int
+	dir    string
+}
+
+var _ = Suite(&SuiteBuild{})
+
+func (s *SuiteBuild) SetUpTest(c *C) {
+	var err error
+	s.builds = 0
+	s.server, err = testing.NewServer("127.0.0.1:0", nil, func(r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/build") {
+			s.builds++
+		}
+	})
+	c.Assert(err, IsNil)
+
+	s.client, err = docker.NewClient(s.server.URL())
+	c.Assert(err, IsNil)
+
+	s.dir = c.MkDir()
+	s.writeFile(c, "Dockerfile", "FROM alpine\nCOPY run.sh /\n")
+	s.writeFile(c, "run.sh", "echo foo")
+
+	pulls = &pullCache{images: make(map[string]*pulledImage)}
+}
+
+func (s *SuiteBuild) writeFile(c *C, name, content string) {
+	c.Assert(os.WriteFile(filepath.Join(s.dir, name), []byte(content), 0o644), IsNil)
+}
+
+func (s *SuiteBuild) TestHash(c *C) {
+	b := &imageBuild{Context: s.dir}
+	hash, err := b.hash()
+	c.Assert(err, IsNil)
+
+	// ignored files don't change the hash
+	s.writeFile(c, ".dockerignore", "*.log\n")
+	withIgnore, err := b.hash()
+	c.Assert(err, IsNil)
+	c.Assert(withIgnore, Not(Equals), hash)
+
+	s.writeFile(c, "debug.log", "foo")
+	ignored, err := b.hash()
+	c.Assert(err, IsNil)
+	c.Assert(ignored, Equals, withIgnore)
+
+	s.writeFile(c, "run.sh", "echo bar")
+	changed, err := b.hash()
+	c.Assert(err, IsNil)
+	c.Assert(changed, Not(Equals), withIgnore)
+
+	b.Args = []string{"VERSION=1"}
+	withArgs, err := b.hash()
+	c.Assert(err, IsNil)
+	c.Assert(withArgs, Not(Equals), changed)
+
+	// an image of another platform is built again
+	b.Platform = "linux/arm64"
+	withPlatform, err := b.hash()
+	c.Assert(err, IsNil)
+	c.Assert(withPlatform, Not(Equals), withArgs)
+}
+
+func (s *SuiteBuild) TestHashMissingContext(c *C) {
+	_, err := (&imageBuild{Context: filepath.Join(s.dir, "missing")}).hash()
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteBuild) TestName(c *C) {
+	b := &imageBuild{Context: s.dir}
+	hash, err := b.hash()
+	c.Assert(err, IsNil)
+
+	name, err := b.name("", "Nightly Report")
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "ofelia/nightly-report:"+hash[:buildTagLength])
+
+	name, err = b.name("registry:5000/scripts:latest", "foo")
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "registry:5000/scripts:"+hash[:buildTagLength])
+}
+
+func (s *SuiteBuild) TestBuildArgs(c *C) {
+	args, err := (&imageBuild{Args: []string{"VERSION=1.2", "EMPTY="}}).buildArgs()
+	c.Assert(err, IsNil)
+	c.Assert(args, DeepEquals, []docker.BuildArg{
+		{Name: "VERSION", Value: "1.2"},
+		{Name: "EMPTY", Value: ""},
+	})
+
+	_, err = (&imageBuild{Args: []string{"VERSION"}}).buildArgs()
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteBuild) TestEnsureBuiltImage(c *C) {
+	b := &imageBuild{Context: s.dir}
+
+	name, err := ensureBuiltImage(newContext(&RunJob{}), s.client, b, "scripts")
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(name, "scripts:"), Equals, true)
+	c.Assert(s.builds, Equals, 1)
+
+	_, err = s.client.InspectImage(name)
+	c.Assert(err, IsNil)
+
+	// same context, the image is not built again
+	cached, err := ensureBuiltImage(newContext(&RunJob{}), s.client, b, "scripts")
+	c.Assert(err, IsNil)
+	c.Assert(cached, Equals, name)
+	c.Assert(s.builds, Equals, 1)
+
+	s.writeFile(c, "run.sh", "echo bar")
+	changed, err := ensureBuiltImage(newContext(&RunJob{}), s.client, b, "scripts")
+	c.Assert(err, IsNil)
+	c.Assert(changed, Not(Equals), name)
+	c.Assert(s.builds, Equals, 2)
+}
@@ -0,0 +1,335 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	calendarDateFormat  = "2006-01-02"
+	calendarTimeFormat  = "15:04"
+	icalDateFormat      = "20060102"
+	icalDateTimeFormat  = "20060102T150405"
+	icalDateTimeUTCForm = "20060102T150405Z"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Calendar is a named list of dates and time windows during which the jobs
+// referencing it are not executed, eg.: public holidays or maintenance
+// freezes.
+type Calendar struct {
+	// Date is a list of days, `2006-01-02`, or ranges of days,
+	// `2006-01-02/2006-01-05`, both ends included.
+	Date []string
+	// Window is a list of weekdays and/or a time range, eg.: `sat,sun`,
+	// `mon-fri 22:00-06:00` or `02:00-04:00`.
+	Window []string
+	// ICal is a list of iCalendar files, every VEVENT is a blackout period.
+	ICal []string `gcfg:"ical" mapstructure:"ical"`
+}
+
+// Contains returns a description of the first entry of the calendar that
+// includes the given time, or an empty string if none does.
+func (c *Calendar) Contains(t time.Time) (string, error) {
+	for _, d := range c.Date {
+		ok, err := dateContains(d, t)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return "date " + d, nil
+		}
+	}
+
+	for _, w := range c.Window {
+		ok, err := windowContains(w, t)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return "window " + w, nil
+		}
+	}
+
+	for _, f := range c.ICal {
+		summary, ok, err := icalContains(f, t)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return fmt.Sprintf("event %q", summary), nil
+		}
+	}
+
+	return "", nil
+}
+
+func dateContains(value string, t time.Time) (bool, error) {
+	from, until, _ := strings.Cut(value, "/")
+	if until == "" {
+		until = from
+	}
+
+	start, err := time.ParseInLocation(calendarDateFormat, strings.TrimSpace(from), t.Location())
+	if err != nil {
+		return false, fmt.Errorf("invalid calendar date %q: %w", value, err)
+	}
+
+	end, err := time.ParseInLocation(calendarDateFormat, strings.TrimSpace(until), t.Location())
+	if err != nil {
+		return false, fmt.Errorf("invalid calendar date %q: %w", value, err)
+	}
+
+	return !t.Before(start) && t.Before(end.AddDate(0, 0, 1)), nil
+}
+
+func windowContains(value string, t time.Time) (bool, error) {
+	days := map[time.Weekday]bool{}
+	var start, end time.Duration
+	var hasTime bool
+
+	for _, field := range strings.Fields(value) {
+		if strings.Contains(field, ":") {
+			var err error
+			start, end, err = parseTimeRange(field)
+			if err != nil {
+				return false, fmt.Errorf("invalid calendar window %q: %w", value, err)
+			}
+
+			hasTime = true
+			continue
+		}
+
+		if err := parseWeekdays(field, days); err != nil {
+			return false, fmt.Errorf("invalid calendar window %q: %w", value, err)
+		}
+	}
+
+	if len(days) == 0 {
+		if !hasTime {
+			return false, fmt.Errorf("invalid calendar window %q: empty window", value)
+		}
+
+		for _, d := range weekdays {
+			days[d] = true
+		}
+	}
+
+	if !hasTime {
+		return days[t.Weekday()], nil
+	}
+
+	y, m, d := t.Date()
+	clock := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	if start < end {
+		return days[t.Weekday()] && clock >= start && clock < end, nil
+	}
+
+	// the window crosses midnight, so it belongs to the day it started
+	yesterday := t.AddDate(0, 0, -1).Weekday()
+	return (days[t.Weekday()] && clock >= start) || (days[yesterday] && clock < end), nil
+}
+
+func parseTimeRange(value string) (time.Duration, time.Duration, error) {
+	from, until, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("missing end of time range %q", value)
+	}
+
+	start, err := parseClock(from)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := parseClock(until)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return start, end, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	c, err := time.Parse(calendarTimeFormat, value)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(c.Hour())*time.Hour + time.Duration(c.Minute())*time.Minute, nil
+}
+
+func parseWeekdays(value string, days map[time.Weekday]bool) error {
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+		from, until, isRange := strings.Cut(part, "-")
+		start, ok := weekdays[from]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", from)
+		}
+
+		if !isRange {
+			days[start] = true
+			continue
+		}
+
+		end, ok := weekdays[until]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", until)
+		}
+
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+type icalEvent struct {
+	summary    string
+	start, end time.Time
+}
+
+func icalContains(filename string, t time.Time) (string, bool, error) {
+	events, err := readICal(filename, t.Location())
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, e := range events {
+		if !t.Before(e.start) && t.Before(e.end) {
+			return e.summary, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// readICal returns the events of the given iCalendar file, recurrence rules
+// are not supported, only the DTSTART and DTEND of each event are used.
+func readICal(filename string, loc *time.Location) ([]icalEvent, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading calendar %q: %w", filename, err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// long lines are folded, continuing on lines starting with a space
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading calendar %q: %w", filename, err)
+	}
+
+	var events []icalEvent
+	var current *icalEvent
+	var allDay bool
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		name, params, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				current = &icalEvent{}
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || current == nil {
+				continue
+			}
+
+			if current.end.IsZero() {
+				current.end = current.start
+				if allDay {
+					current.end = current.start.AddDate(0, 0, 1)
+				}
+			}
+
+			events = append(events, *current)
+			current = nil
+		case "SUMMARY":
+			if current != nil {
+				current.summary = value
+			}
+		case "DTSTART", "DTEND":
+			if current == nil {
+				continue
+			}
+
+			v, date, err := parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("error reading calendar %q: %w", filename, err)
+			}
+
+			if strings.EqualFold(name, "DTSTART") {
+				current.start, allDay = v, date
+			} else {
+				current.end = v
+			}
+		}
+	}
+
+	return events, nil
+}
+
+func parseICalTime(value, params string, loc *time.Location) (time.Time, bool, error) {
+	for _, p := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(p, "=")
+		if !strings.EqualFold(k, "TZID") {
+			continue
+		}
+
+		l, err := time.LoadLocation(v)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+
+		loc = l
+	}
+
+	switch len(value) {
+	case len(icalDateFormat):
+		t, err := time.ParseInLocation(icalDateFormat, value, loc)
+		return t, true, err
+	case len(icalDateTimeUTCForm):
+		t, err := time.Parse(icalDateTimeUTCForm, value)
+		return t, false, err
+	default:
+		t, err := time.ParseInLocation(icalDateTimeFormat, value, loc)
+		return t, false, err
+	}
+}
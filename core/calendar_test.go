@@ -0,0 +1,105 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type SuiteCalendar struct{}
+
+var _ = Suite(&SuiteCalendar{})
+
+func (s *SuiteCalendar) TestContainsDate(c *C) {
+	cal := &Calendar{Date: []string{"2026-12-25", "2026-12-30/2027-01-01"}}
+
+	testcases := []struct {
+		time     time.Time
+		expected string
+	}{
+		{time.Date(2026, 12, 24, 23, 59, 0, 0, time.Local), ""},
+		{time.Date(2026, 12, 25, 0, 0, 0, 0, time.Local), "date 2026-12-25"},
+		{time.Date(2026, 12, 25, 23, 59, 0, 0, time.Local), "date 2026-12-25"},
+		{time.Date(2026, 12, 31, 12, 0, 0, 0, time.Local), "date 2026-12-30/2027-01-01"},
+		{time.Date(2027, 1, 2, 0, 0, 0, 0, time.Local), ""},
+	}
+
+	for _, tc := range testcases {
+		entry, err := cal.Contains(tc.time)
+		c.Assert(err, IsNil)
+		c.Assert(entry, Equals, tc.expected, Commentf("%s", tc.time))
+	}
+}
+
+func (s *SuiteCalendar) TestContainsWindow(c *C) {
+	// 2026-10-16 is a friday
+	testcases := []struct {
+		window   string
+		time     time.Time
+		expected bool
+	}{
+		{"sat,sun", time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local), true},
+		{"sat,sun", time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local), false},
+		{"mon-fri", time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local), true},
+		{"fri-mon", time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local), true},
+		{"fri-mon", time.Date(2026, 10, 14, 12, 0, 0, 0, time.Local), false},
+		{"02:00-04:00", time.Date(2026, 10, 14, 3, 0, 0, 0, time.Local), true},
+		{"02:00-04:00", time.Date(2026, 10, 14, 4, 0, 0, 0, time.Local), false},
+		{"fri 22:00-06:00", time.Date(2026, 10, 16, 23, 0, 0, 0, time.Local), true},
+		{"fri 22:00-06:00", time.Date(2026, 10, 17, 5, 0, 0, 0, time.Local), true},
+		{"fri 22:00-06:00", time.Date(2026, 10, 16, 5, 0, 0, 0, time.Local), false},
+	}
+
+	for _, tc := range testcases {
+		cal := &Calendar{Window: []string{tc.window}}
+		entry, err := cal.Contains(tc.time)
+		c.Assert(err, IsNil)
+		c.Assert(entry != "", Equals, tc.expected, Commentf("%s at %s", tc.window, tc.time))
+	}
+}
+
+func (s *SuiteCalendar) TestContainsInvalid(c *C) {
+	now := time.Now()
+
+	_, err := (&Calendar{Date: []string{"25/12/2026"}}).Contains(now)
+	c.Assert(err, NotNil)
+
+	_, err = (&Calendar{Window: []string{"someday"}}).Contains(now)
+	c.Assert(err, NotNil)
+
+	_, err = (&Calendar{Window: []string{"mon 10:00"}}).Contains(now)
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteCalendar) TestContainsICal(c *C) {
+	filename := filepath.Join(c.MkDir(), "holidays.ics")
+	err := os.WriteFile(filename, []byte("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"SUMMARY:Christmas\r\n"+
+		"DTSTART;VALUE=DATE:20261225\r\n"+
+		"END:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\n"+
+		"SUMMARY:Database mig\r\n"+
+		" ration\r\n"+
+		"DTSTART:20261020T100000Z\r\n"+
+		"DTEND:20261020T120000Z\r\n"+
+		"END:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0644)
+	c.Assert(err, IsNil)
+
+	cal := &Calendar{ICal: []string{filename}}
+
+	entry, err := cal.Contains(time.Date(2026, 12, 25, 10, 0, 0, 0, time.Local))
+	c.Assert(err, IsNil)
+	c.Assert(entry, Equals, `event "Christmas"`)
+
+	entry, err = cal.Contains(time.Date(2026, 10, 20, 11, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Assert(entry, Equals, `event "Database migration"`)
+
+	entry, err = cal.Contains(time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Assert(entry, Equals, "")
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
@@ -30,9 +31,12 @@ const (
 type Job interface {
 	GetName() string
 	GetSchedule() string
+	GetSchedules() []string
 	GetCommand() string
-	GetCronJobID() int
-	SetCronJobID(int)
+	ShouldRunOnStart() bool
+	GetJitter() string
+	GetCronJobIDs() []int
+	SetCronJobIDs([]int)
 	Middlewares() []Middleware
 	Use(...Middleware)
 	Run(*Context) error
@@ -142,39 +146,96 @@ type Execution struct {
 	Failed    bool
 	Skipped   bool
 	Error     error
+	// ScheduledTime is when the execution was scheduled, before any delay
+	// like the jitter, for the executions started by a schedule
+	ScheduledTime time.Time
+	// Attempt is the number of attempt of the execution, starting at 1
+	Attempt int
 
 	OutputStream, ErrorStream *circbuf.Buffer `json:"-"`
+	// Artifacts are the files copied out of the job once it finished
+	Artifacts []Artifact
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
 }
 
 // NewExecution returns a new Execution, with a random ID
 func NewExecution() *Execution {
 	bufOut, _ := circbuf.NewBuffer(maxStreamSize)
 	bufErr, _ := circbuf.NewBuffer(maxStreamSize)
+	ctx, cancel := context.WithCancelCause(context.Background())
 	return &Execution{
 		ID:           randomID(),
+		Attempt:      1,
 		OutputStream: bufOut,
 		ErrorStream:  bufErr,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Context returns a context that is done when the execution is canceled, the
+// jobs should stop as soon as possible, returning the cancellation cause.
+func (e *Execution) Context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+
+	return e.ctx
+}
+
+// Cancel cancels a running execution, the given cause is the error returned
+// by the job, an error wrapping ErrSkippedExecution marks it as skipped.
+func (e *Execution) Cancel(cause error) {
+	if e.cancel != nil {
+		e.cancel(cause)
 	}
 }
 
+// Canceled returns the cause of the cancellation if the execution was
+// canceled or nil otherwise.
+func (e *Execution) Canceled() error {
+	return context.Cause(e.Context())
+}
+
 // Start start the exection, initialize the running flags and the start date.
 func (e *Execution) Start() {
 	e.IsRunning = true
 	e.Date = time.Now()
 }
 
+// scheduled returns when the execution was scheduled, its start date when it
+// was not started by a schedule
+func (e *Execution) scheduled() time.Time {
+	if e.ScheduledTime.IsZero() {
+		return e.Date
+	}
+
+	return e.ScheduledTime
+}
+
 // Stop stops the executions, if a ErrSkippedExecution is given the exection
 // is mark as skipped, if any other error is given the exection is mark as
 // failed. Also mark the exection as IsRunning false and save the duration time
+//
+// An error wrapping ErrSkippedExecution can be given to record the reason of
+// the skip, it is kept at Error without marking the execution as failed.
 func (e *Execution) Stop(err error) {
 	e.IsRunning = false
 	e.Duration = time.Since(e.Date)
+	e.Cancel(nil)
 
-	if err != nil && err != ErrSkippedExecution {
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrSkippedExecution):
+		e.Skipped = true
+		if err != ErrSkippedExecution {
+			e.Error = err
+		}
+	default:
 		e.Error = err
 		e.Failed = true
-	} else if err == ErrSkippedExecution {
-		e.Skipped = true
 	}
 }
 
@@ -249,7 +310,7 @@ func buildFindLocalImageOptions(image string) docker.ListImagesOptions {
 	}
 }
 
-func buildPullOptions(image string) (docker.PullImageOptions, docker.AuthConfiguration) {
+func buildPullOptions(image string) docker.PullImageOptions {
 	repository, tag := docker.ParseRepositoryTag(image)
 
 	registry := parseRegistry(repository)
@@ -262,7 +323,7 @@ func buildPullOptions(image string) (docker.PullImageOptions, docker.AuthConfigu
 		Repository: repository,
 		Registry:   registry,
 		Tag:        tag,
-	}, buildAuthConfiguration(registry)
+	}
 }
 
 func parseRegistry(repository string) string {
@@ -277,27 +338,3 @@ func parseRegistry(repository string) string {
 
 	return ""
 }
-
-func buildAuthConfiguration(registry string) docker.AuthConfiguration {
-	var auth docker.AuthConfiguration
-	if dockercfg == nil {
-		return auth
-	}
-
-	if v, ok := dockercfg.Configs[registry]; ok {
-		return v
-	}
-
-	// try to fetch configs from docker hub default registry urls
-	// see example here: https://www.projectatomic.io/blog/2016/03/docker-credentials-store/
-	if registry == "" {
-		if v, ok := dockercfg.Configs["https://index.docker.io/v2/"]; ok {
-			return v
-		}
-		if v, ok := dockercfg.Configs["https://index.docker.io/v1/"]; ok {
-			return v
-		}
-	}
-
-	return auth
-}
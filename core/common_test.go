@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	logging "github.com/op/go-logging"
 	. "gopkg.in/check.v1"
 )
 
@@ -230,6 +231,39 @@ func (s *SuiteCommon) TestExecutionStopErrorSkip(c *C) {
 	c.Assert(exe.Duration > 0, Equals, true)
 }
 
+func (s *SuiteCommon) TestExecutionStopErrorSkipWithReason(c *C) {
+	err := fmt.Errorf("%w: foo", ErrSkippedExecution)
+
+	exe := &Execution{}
+	exe.Start()
+	exe.Stop(err)
+
+	c.Assert(exe.IsRunning, Equals, false)
+	c.Assert(exe.Failed, Equals, false)
+	c.Assert(exe.Skipped, Equals, true)
+	c.Assert(exe.Error, Equals, err)
+}
+
+func (s *SuiteCommon) TestExecutionCancel(c *C) {
+	err := errors.New("foo")
+
+	exe := NewExecution()
+	exe.Start()
+	c.Assert(exe.Canceled(), IsNil)
+
+	exe.Cancel(err)
+	<-exe.Context().Done()
+	c.Assert(exe.Canceled(), Equals, err)
+}
+
+func (s *SuiteCommon) TestExecutionContextEmpty(c *C) {
+	exe := &Execution{}
+	exe.Cancel(errors.New("foo"))
+
+	c.Assert(exe.Context(), NotNil)
+	c.Assert(exe.Canceled(), IsNil)
+}
+
 func (s *SuiteCommon) TestMiddlewareContainerUseTwice(c *C) {
 	mA := &TestMiddleware{}
 	mB := &TestMiddleware{}
@@ -317,6 +351,12 @@ func (*TestLogger) Errorf(format string, args ...interface{})    {}
 func (*TestLogger) Noticef(format string, args ...interface{})   {}
 func (*TestLogger) Warningf(format string, args ...interface{})  {}
 
+// newContext returns the context of a new execution of the job, logging to
+// the ofelia logger
+func newContext(job Job) *Context {
+	return &Context{Execution: NewExecution(), Job: job, Logger: logging.MustGetLogger("ofelia")}
+}
+
 func (s *SuiteCommon) TestParseRegistry(c *C) {
 	c.Assert(parseRegistry("example.com:port/dir/image"), Equals, "example.com:port")
 	c.Assert(parseRegistry("example.com:port/image"), Equals, "example.com:port")
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvJobName is the name of the job
+	EnvJobName = "OFELIA_JOB_NAME"
+	// EnvJobType is the type of the job, eg.: job-run
+	EnvJobType = "OFELIA_JOB_TYPE"
+	// EnvExecutionID is the ID of the execution
+	EnvExecutionID = "OFELIA_EXECUTION_ID"
+	// EnvScheduledTime is when the execution was scheduled, as RFC 3339
+	EnvScheduledTime = "OFELIA_SCHEDULED_TIME"
+	// EnvAttempt is the number of attempt of the execution
+	EnvAttempt = "OFELIA_ATTEMPT"
+)
+
+// buildEnvironment merges the variables of the env files, the variables of
+// the host and the given ones, each taking precedence over the previous, and
+// returns them as `name=value`
+func buildEnvironment(files, fromHost, env []string) ([]string, error) {
+	if len(files) == 0 && len(fromHost) == 0 {
+		return env, nil
+	}
+
+	var vars []string
+	for _, f := range files {
+		fileVars, err := parseEnvFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		vars = append(vars, fileVars...)
+	}
+
+	// the host variables are a comma separated list of names, the ones not
+	// set are ignored
+	for _, names := range fromHost {
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if value, ok := os.LookupEnv(name); ok && name != "" {
+				vars = append(vars, name+"="+value)
+			}
+		}
+	}
+
+	return mergeEnvironment(append(vars, env...)), nil
+}
+
+// executionEnvironment adds the variables describing the execution of the
+// given type of job to the given ones, which take precedence over them
+func executionEnvironment(ctx *Context, jobType string, env []string) []string {
+	var vars []string
+	if jobType != "" {
+		vars = append(vars, EnvJobType+"="+jobType)
+	}
+
+	if ctx.Job != nil {
+		vars = append(vars, EnvJobName+"="+ctx.Job.GetName())
+	}
+
+	if e := ctx.Execution; e != nil {
+		vars = append(vars, EnvExecutionID+"="+e.ID, EnvAttempt+"="+strconv.Itoa(e.Attempt))
+		if scheduled := e.scheduled(); !scheduled.IsZero() {
+			vars = append(vars, EnvScheduledTime+"="+scheduled.Format(time.RFC3339))
+		}
+	}
+
+	return mergeEnvironment(append(vars, env...))
+}
+
+// mergeEnvironment removes the repeated variables, keeping the last value at
+// the position of the first one
+func mergeEnvironment(vars []string) []string {
+	index := make(map[string]int, len(vars))
+	merged := make([]string, 0, len(vars))
+	for _, v := range vars {
+		name, _, _ := strings.Cut(v, "=")
+		if i, ok := index[name]; ok {
+			merged[i] = v
+			continue
+		}
+
+		index[name] = len(merged)
+		merged = append(merged, v)
+	}
+
+	return merged
+}
+
+// parseEnvFile reads a dotenv file, with a `name=value` per line, optionally
+// prefixed by `export`. Values can be quoted, blank lines and comments
+// starting with `#` are ignored.
+func parseEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading env file: %w", err)
+	}
+
+	defer f.Close()
+
+	var vars []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid line %d of env file %q, expected name=value", n, path)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s in env file %q: %w", name, path, err)
+		}
+
+		vars = append(vars, name+"="+value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading env file %q: %w", path, err)
+	}
+
+	return vars, nil
+}
+
+// parseEnvValue unquotes the value, escapes are only interpreted in double
+// quotes, and strips the comments of the unquoted ones
+func parseEnvValue(value string) (string, error) {
+	if len(value) >= 2 && value[0] == value[len(value)-1] {
+		switch value[0] {
+		case '\'':
+			return value[1 : len(value)-1], nil
+		case '"':
+			return strconv.Unquote(value)
+		}
+	}
+
+	if i := strings.Index(value, " #"); i != -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	return value, nil
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type SuiteEnv struct{}
+
+var _ = Suite(&SuiteEnv{})
+
+func (s *SuiteEnv) TestParseEnvFile(c *C) {
+	path := writeEnvFile(c, `# database
+export DB_HOST=db.local
+DB_USER = backup # inline comment
+DB_PASSWORD='p#ss word'
+GREETING="hello\nworld"
+EMPTY=
+`)
+
+	vars, err := parseEnvFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(vars, DeepEquals, []string{
+		"DB_HOST=db.local",
+		"DB_USER=backup",
+		"DB_PASSWORD=p#ss word",
+		"GREETING=hello\nworld",
+		"EMPTY=",
+	})
+}
+
+func (s *SuiteEnv) TestParseEnvFileInvalid(c *C) {
+	_, err := parseEnvFile(writeEnvFile(c, "FOO=bar\nBAZ\n"))
+	c.Assert(err, ErrorMatches, `invalid line 2 of env file .*`)
+
+	_, err = parseEnvFile(filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, ErrorMatches, `error reading env file: .*`)
+}
+
+func (s *SuiteEnv) TestBuildEnvironment(c *C) {
+	os.Setenv("OFELIA_TEST_HOST", "host")
+	os.Setenv("OFELIA_TEST_FOO", "host")
+	defer os.Unsetenv("OFELIA_TEST_HOST")
+	defer os.Unsetenv("OFELIA_TEST_FOO")
+
+	path := writeEnvFile(c, "OFELIA_TEST_FOO=file\nOFELIA_TEST_BAR=file\nOFELIA_TEST_BAZ=file\n")
+
+	env, err := buildEnvironment(
+		[]string{path},
+		[]string{"OFELIA_TEST_FOO, OFELIA_TEST_HOST", "OFELIA_TEST_UNSET"},
+		[]string{"OFELIA_TEST_BAR=job"},
+	)
+	c.Assert(err, IsNil)
+	c.Assert(env, DeepEquals, []string{
+		"OFELIA_TEST_FOO=host",
+		"OFELIA_TEST_BAR=job",
+		"OFELIA_TEST_BAZ=file",
+		"OFELIA_TEST_HOST=host",
+	})
+
+	env, err = buildEnvironment(nil, nil, []string{"FOO=bar"})
+	c.Assert(err, IsNil)
+	c.Assert(env, DeepEquals, []string{"FOO=bar"})
+}
+
+func writeEnvFile(c *C, content string) string {
+	path := filepath.Join(c.MkDir(), ".env")
+	c.Assert(os.WriteFile(path, []byte(content), 0o600), IsNil)
+	return path
+}
+
+func (s *SuiteEnv) TestExecutionEnvironment(c *C) {
+	job := &LocalJob{}
+	job.Name = "backup"
+
+	e := NewExecution()
+	e.ScheduledTime = time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC)
+	ctx := &Context{Execution: e, Job: job}
+
+	env := executionEnvironment(ctx, "job-local", []string{"FOO=bar", "OFELIA_ATTEMPT=2"})
+	c.Assert(env, DeepEquals, []string{
+		"OFELIA_JOB_TYPE=job-local",
+		"OFELIA_JOB_NAME=backup",
+		"OFELIA_EXECUTION_ID=" + e.ID,
+		"OFELIA_ATTEMPT=2",
+		"OFELIA_SCHEDULED_TIME=2026-10-19T01:00:00Z",
+		"FOO=bar",
+	})
+}
@@ -1,19 +1,75 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/gobs/args"
 )
 
+const (
+	// UnavailableFail fails the execution when the container is unavailable
+	UnavailableFail = "fail"
+	// UnavailableSkip skips the execution when the container is unavailable
+	UnavailableSkip = "skip"
+	// UnavailableStart starts the container when it is stopped, and waits for
+	// it to become available
+	UnavailableStart = "start"
+	// UnavailableWait waits for the container to become available
+	UnavailableWait = "wait"
+
+	defaultWaitTimeout = time.Minute
+)
+
+const (
+	// TargetsOne runs the command in one of the matching containers, an
+	// available one if any
+	TargetsOne = "one"
+	// TargetsAll runs the command in every matching container
+	TargetsAll = "all"
+
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+)
+
 type ExecJob struct {
-	BareJob     `mapstructure:",squash"`
-	Client      *docker.Client `json:"-" hash:"-"`
-	Container   string
+	BareJob   `mapstructure:",squash"`
+	Client    *docker.Client `json:"-" hash:"-"`
+	Container string
+	// ContainerLabel, ComposeProject and ComposeService select the target
+	// containers by label, Container is ignored when any of them is set
+	ContainerLabel []string `gcfg:"container-label" mapstructure:"container-label"`
+	ComposeProject string   `gcfg:"compose-project" mapstructure:"compose-project"`
+	ComposeService string   `gcfg:"compose-service" mapstructure:"compose-service"`
+	// Targets is in how many of the selected containers the command runs,
+	// TargetsOne or TargetsAll
+	Targets     string
 	User        string `default:"root"`
 	TTY         bool   `default:"false"`
 	Environment []string
+	// EnvFile are dotenv files and EnvFromHost names of variables of the host,
+	// merged with Environment, see buildEnvironment
+	EnvFile     []string `gcfg:"env-file" mapstructure:"env-file"`
+	EnvFromHost []string `gcfg:"env-from-host" mapstructure:"env-from-host"`
+	WorkingDir  string   `gcfg:"working-dir" mapstructure:"working-dir"`
+	Privileged  bool
+	// Detach starts the command in background, its completion is checked
+	// inspecting the exec, and its output is not collected
+	Detach bool
+	// OnUnavailable is what to do when the container is not running, one of
+	// UnavailableFail, UnavailableSkip, UnavailableStart and UnavailableWait
+	OnUnavailable string `gcfg:"on-unavailable" mapstructure:"on-unavailable"`
+	// RequireHealthy makes a container with a starting or unhealthy health
+	// check unavailable too
+	RequireHealthy bool `gcfg:"require-healthy" mapstructure:"require-healthy"`
+	// WaitTimeout is how long to wait for the container to become available
+	WaitTimeout string `gcfg:"wait-timeout" mapstructure:"wait-timeout"`
 
 	execID string
 }
@@ -23,7 +79,52 @@ func NewExecJob(c *docker.Client) *ExecJob {
 }
 
 func (j *ExecJob) Run(ctx *Context) error {
-	exec, err := j.buildExec()
+	targets, err := j.selectTargets()
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 1 && j.Targets != TargetsAll {
+		return j.runIn(ctx, targets[0], ctx.Execution.OutputStream, ctx.Execution.ErrorStream)
+	}
+
+	// the output of every container is prefixed with its name, and the
+	// execution fails if the command fails in any of them
+	var errs []error
+	var skipped int
+	for _, target := range targets {
+		if cause := ctx.Execution.Canceled(); cause != nil {
+			return cause
+		}
+
+		stdout := newPrefixWriter(ctx.Execution.OutputStream, target+": ")
+		stderr := newPrefixWriter(ctx.Execution.ErrorStream, target+": ")
+		err := j.runIn(ctx, target, stdout, stderr)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrSkippedExecution):
+			ctx.Log(err.Error())
+			skipped++
+		default:
+			ctx.Warn(fmt.Sprintf("failed in container %s: %s", target, err))
+			errs = append(errs, fmt.Errorf("%s: %w", target, err))
+		}
+	}
+
+	if skipped == len(targets) {
+		return fmt.Errorf("%w: no available containers", ErrSkippedExecution)
+	}
+
+	return errors.Join(errs...)
+}
+
+// runIn runs the command in the given container
+func (j *ExecJob) runIn(ctx *Context, container string, stdout, stderr io.Writer) error {
+	if err := j.ensureAvailable(ctx, container); err != nil {
+		return err
+	}
+
+	exec, err := j.buildExec(ctx, container)
 	if err != nil {
 		return err
 	}
@@ -32,11 +133,13 @@ func (j *ExecJob) Run(ctx *Context) error {
 		j.execID = exec.ID
 	}
 
-	if err := j.startExec(ctx.Execution); err != nil {
-		return err
+	var inspect *docker.ExecInspect
+	if j.Detach {
+		inspect, err = j.runDetachedExec(ctx)
+	} else {
+		inspect, err = j.runExec(ctx, stdout, stderr)
 	}
 
-	inspect, err := j.inspectExec()
 	if err != nil {
 		return err
 	}
@@ -51,18 +154,137 @@ func (j *ExecJob) Run(ctx *Context) error {
 	}
 }
 
-func (j *ExecJob) buildExec() (*docker.Exec, error) {
-	exec, err := j.Client.CreateExec(docker.CreateExecOptions{
+// selectTargets returns the names of the containers the command runs in,
+// the given container unless a selector is set
+func (j *ExecJob) selectTargets() ([]string, error) {
+	switch j.Targets {
+	case "", TargetsOne, TargetsAll:
+	default:
+		return nil, fmt.Errorf("unknown targets %q, expected %s or %s", j.Targets, TargetsOne, TargetsAll)
+	}
+
+	filter := append([]string{}, j.ContainerLabel...)
+	if j.ComposeProject != "" {
+		filter = append(filter, composeProjectLabel+"="+j.ComposeProject)
+	}
+
+	if j.ComposeService != "" {
+		filter = append(filter, composeServiceLabel+"="+j.ComposeService)
+	}
+
+	if len(filter) == 0 {
+		return []string{j.Container}, nil
+	}
+
+	containers, err := j.Client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: map[string][]string{"label": filter},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %s", err)
+	}
+
+	var names, available []string
+	for _, c := range containers {
+		// containers of `docker compose run` are not replicas of the service
+		if c.Labels[composeOneoffLabel] == "True" || len(c.Names) == 0 {
+			continue
+		}
+
+		name := strings.TrimPrefix(c.Names[0], "/")
+		names = append(names, name)
+		healthy := !strings.Contains(c.Status, "(unhealthy)") &&
+			!strings.Contains(c.Status, "(health: starting)")
+		if c.State == "running" && (healthy || !j.RequireHealthy) {
+			available = append(available, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no containers matching %s", strings.Join(filter, ", "))
+	}
+
+	sort.Strings(names)
+	sort.Strings(available)
+
+	switch {
+	case j.Targets == TargetsAll:
+		return names, nil
+	case len(available) != 0:
+		return available[:1], nil
+	default:
+		return names[:1], nil
+	}
+}
+
+func (j *ExecJob) runExec(ctx *Context, stdout, stderr io.Writer) (*docker.ExecInspect, error) {
+	if err := j.startExec(ctx.Execution, stdout, stderr); err != nil {
+		if cause := ctx.Execution.Canceled(); cause != nil {
+			return nil, cause
+		}
+
+		return nil, err
+	}
+
+	return j.inspectExec()
+}
+
+// runDetachedExec starts the exec without attaching to it, and inspects it
+// until it finishes
+func (j *ExecJob) runDetachedExec(ctx *Context) (*docker.ExecInspect, error) {
+	if err := j.Client.StartExec(j.execID, docker.StartExecOptions{
+		Detach:  true,
+		Context: ctx.Execution.Context(),
+	}); err != nil {
+		return nil, fmt.Errorf("error starting exec: %s", err)
+	}
+
+	ticker := time.NewTicker(watchDuration)
+	defer ticker.Stop()
+
+	for {
+		inspect, err := j.inspectExec()
+		if err != nil || !inspect.Running {
+			return inspect, err
+		}
+
+		select {
+		case <-ctx.Execution.Context().Done():
+			return nil, ctx.Execution.Canceled()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *ExecJob) buildExecOptions(container string) (docker.CreateExecOptions, error) {
+	env, err := buildEnvironment(j.EnvFile, j.EnvFromHost, j.Environment)
+	if err != nil {
+		return docker.CreateExecOptions{}, err
+	}
+
+	return docker.CreateExecOptions{
 		AttachStdin:  false,
-		AttachStdout: true,
-		AttachStderr: true,
+		AttachStdout: !j.Detach,
+		AttachStderr: !j.Detach,
 		Tty:          j.TTY,
 		Cmd:          args.GetArgs(j.Command),
-		Container:    j.Container,
+		Container:    container,
 		User:         j.User,
-		Env:          j.Environment,
-	})
+		Env:          env,
+		WorkingDir:   j.WorkingDir,
+		Privileged:   j.Privileged,
+	}, nil
+}
+
+func (j *ExecJob) buildExec(ctx *Context, container string) (*docker.Exec, error) {
+	opts, err := j.buildExecOptions(container)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Env = executionEnvironment(ctx, j.GetType(), opts.Env)
 
+	exec, err := j.Client.CreateExec(opts)
 	if err != nil {
 		return exec, fmt.Errorf("error creating exec: %s", err)
 	}
@@ -70,12 +292,15 @@ func (j *ExecJob) buildExec() (*docker.Exec, error) {
 	return exec, nil
 }
 
-func (j *ExecJob) startExec(e *Execution) error {
+func (j *ExecJob) startExec(e *Execution, stdout, stderr io.Writer) error {
 	err := j.Client.StartExec(j.execID, docker.StartExecOptions{
 		Tty:          j.TTY,
-		OutputStream: e.OutputStream,
-		ErrorStream:  e.ErrorStream,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
 		RawTerminal:  j.TTY,
+		// the command keeps running inside the container when the execution
+		// is canceled, only its output stops being collected
+		Context: e.Context(),
 	})
 
 	if err != nil {
@@ -94,3 +319,117 @@ func (j *ExecJob) inspectExec() (*docker.ExecInspect, error) {
 
 	return i, nil
 }
+
+// ensureAvailable checks that the container is running, and healthy with
+// RequireHealthy, following the on-unavailable option when it isn't
+func (j *ExecJob) ensureAvailable(ctx *Context, name string) error {
+	switch j.OnUnavailable {
+	case "", UnavailableFail, UnavailableSkip, UnavailableStart, UnavailableWait:
+	default:
+		return fmt.Errorf("unknown on-unavailable option %q", j.OnUnavailable)
+	}
+
+	timeout := defaultWaitTimeout
+	if j.WaitTimeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(j.WaitTimeout); err != nil {
+			return fmt.Errorf("invalid wait timeout %q: %s", j.WaitTimeout, err)
+		}
+	}
+
+	container, err := j.Client.InspectContainer(name)
+	if err != nil {
+		return fmt.Errorf("error inspecting container %q: %s", name, err)
+	}
+
+	state := unavailableState(container, j.RequireHealthy)
+	if state == "" {
+		return nil
+	}
+
+	switch j.OnUnavailable {
+	case UnavailableSkip:
+		return fmt.Errorf("%w: container %q is %s", ErrSkippedExecution, name, state)
+	case UnavailableStart:
+		if err := j.startContainer(ctx, name, container); err != nil {
+			return err
+		}
+
+		return j.waitAvailable(ctx, name, timeout)
+	case UnavailableWait:
+		ctx.Log(fmt.Sprintf("Container %s is %s, waiting for it", name, state))
+		return j.waitAvailable(ctx, name, timeout)
+	default:
+		return fmt.Errorf("container %q is %s", name, state)
+	}
+}
+
+func (j *ExecJob) startContainer(ctx *Context, name string, c *docker.Container) error {
+	var err error
+	switch {
+	case c.State.Paused:
+		ctx.Log("Unpausing container " + name)
+		err = j.Client.UnpauseContainer(c.ID)
+	case !c.State.Running && !c.State.Restarting:
+		ctx.Log("Starting container " + name)
+		err = j.Client.StartContainer(c.ID, nil)
+	}
+
+	var running *docker.ContainerAlreadyRunning
+	if err != nil && !errors.As(err, &running) {
+		return fmt.Errorf("error starting container %q: %s", name, err)
+	}
+
+	return nil
+}
+
+// waitAvailable inspects the container until it is available, or the
+// timeout is reached
+func (j *ExecJob) waitAvailable(ctx *Context, name string, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(watchDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Execution.Context().Done():
+			return ctx.Execution.Canceled()
+		case <-deadline.C:
+			return fmt.Errorf("container %q is not available after %s", name, timeout)
+		case <-ticker.C:
+		}
+
+		container, err := j.Client.InspectContainer(name)
+		if err != nil {
+			return fmt.Errorf("error inspecting container %q: %s", name, err)
+		}
+
+		if unavailableState(container, j.RequireHealthy) == "" {
+			return nil
+		}
+	}
+}
+
+// unavailableState returns why the container can't run an exec, or an empty
+// string if it can, the health check is only considered with requireHealthy
+func unavailableState(c *docker.Container, requireHealthy bool) string {
+	switch {
+	case c.State.Restarting:
+		return "restarting"
+	case !c.State.Running:
+		return "not running"
+	case c.State.Paused:
+		return "paused"
+	case !requireHealthy:
+		return ""
+	}
+
+	switch c.State.Health.Status {
+	case "starting", "unhealthy":
+		return c.State.Health.Status
+	}
+
+	return ""
+}
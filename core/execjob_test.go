@@ -4,7 +4,9 @@ import (
 	"archive/tar"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/fsouza/go-dockerclient/testing"
@@ -85,6 +87,200 @@ func (s *SuiteExecJob) TestRun(c *C) {
 	// no way to check for env :|
 }
 
+func (s *SuiteExecJob) TestBuildExecOptions(c *C) {
+	job := &ExecJob{}
+	job.Container = ContainerFixture
+	job.Command = "backup --full"
+	job.WorkingDir = "/var/lib/db"
+	job.Privileged = true
+
+	opts, err := job.buildExecOptions(ContainerFixture)
+	c.Assert(err, IsNil)
+	c.Assert(opts.Cmd, DeepEquals, []string{"backup", "--full"})
+	c.Assert(opts.WorkingDir, Equals, "/var/lib/db")
+	c.Assert(opts.Privileged, Equals, true)
+	c.Assert(opts.AttachStdout, Equals, true)
+
+	job.Detach = true
+	opts, err = job.buildExecOptions(ContainerFixture)
+	c.Assert(err, IsNil)
+	c.Assert(opts.AttachStdout, Equals, false)
+	c.Assert(opts.AttachStderr, Equals, false)
+}
+
+func (s *SuiteExecJob) TestRunDetach(c *C) {
+	var executed bool
+	s.server.PrepareExec("*", func() {
+		executed = true
+	})
+
+	job := &ExecJob{Client: s.client}
+	job.Container = ContainerFixture
+	job.Command = "backup"
+	job.Detach = true
+
+	err := job.Run(newContext(job))
+	c.Assert(err, IsNil)
+	c.Assert(executed, Equals, true)
+}
+
+func (s *SuiteExecJob) TestRunContainerStopped(c *C) {
+	c.Assert(s.client.StopContainer(ContainerFixture, 0), IsNil)
+
+	job := &ExecJob{Client: s.client}
+	job.Container = ContainerFixture
+	job.Command = "backup"
+
+	err := job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `container "test-container" is not running`)
+
+	job.OnUnavailable = UnavailableSkip
+	err = job.Run(newContext(job))
+	c.Assert(errors.Is(err, ErrSkippedExecution), Equals, true)
+
+	job.OnUnavailable = UnavailableWait
+	job.WaitTimeout = "300ms"
+	err = job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `container "test-container" is not available after 300ms`)
+
+	job.OnUnavailable = "foo"
+	err = job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `unknown on-unavailable option "foo"`)
+}
+
+func (s *SuiteExecJob) TestRunContainerStart(c *C) {
+	c.Assert(s.client.StopContainer(ContainerFixture, 0), IsNil)
+
+	job := &ExecJob{Client: s.client}
+	job.Container = ContainerFixture
+	job.Command = "backup"
+	job.OnUnavailable = UnavailableStart
+
+	c.Assert(job.Run(newContext(job)), IsNil)
+
+	container, err := s.client.InspectContainer(ContainerFixture)
+	c.Assert(err, IsNil)
+	c.Assert(container.State.Running, Equals, true)
+}
+
+func (s *SuiteExecJob) TestRunContainerWait(c *C) {
+	c.Assert(s.client.StopContainer(ContainerFixture, 0), IsNil)
+
+	job := &ExecJob{Client: s.client}
+	job.Container = ContainerFixture
+	job.Command = "backup"
+	job.OnUnavailable = UnavailableWait
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.client.StartContainer(ContainerFixture, nil)
+	}()
+
+	c.Assert(job.Run(newContext(job)), IsNil)
+}
+
+func (s *SuiteExecJob) TestRunContainerUnhealthy(c *C) {
+	s.server.CustomHandler("/containers/"+ContainerFixture+"/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(docker.Container{
+			ID:    ContainerFixture,
+			Name:  ContainerFixture,
+			State: docker.State{Running: true, Health: docker.Health{Status: "unhealthy"}},
+		})
+	}))
+
+	job := &ExecJob{Client: s.client}
+	job.Container = ContainerFixture
+	job.Command = "backup"
+
+	// the health check is ignored by default
+	c.Assert(job.Run(newContext(job)), IsNil)
+
+	job.RequireHealthy = true
+	err := job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `container "test-container" is unhealthy`)
+}
+
+func (s *SuiteExecJob) TestRunComposeServiceOne(c *C) {
+	s.buildReplicas(c)
+
+	job := &ExecJob{Client: s.client}
+	job.ComposeProject = "shop"
+	job.ComposeService = "worker"
+	job.Command = "cleanup"
+
+	c.Assert(job.Run(newContext(job)), IsNil)
+	c.Assert(s.execCount(c, "shop-worker-1"), Equals, 0)
+	c.Assert(s.execCount(c, "shop-worker-2"), Equals, 1)
+	c.Assert(s.execCount(c, "shop-worker-3"), Equals, 0)
+	c.Assert(s.execCount(c, "shop-worker-run"), Equals, 0)
+}
+
+func (s *SuiteExecJob) TestRunComposeServiceAll(c *C) {
+	s.buildReplicas(c)
+
+	job := &ExecJob{Client: s.client}
+	job.ComposeService = "worker"
+	job.Targets = TargetsAll
+	job.Command = "cleanup"
+
+	// the stopped replica fails, the command still runs in the others
+	err := job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `shop-worker-1: container "shop-worker-1" is not running`)
+	c.Assert(s.execCount(c, "shop-worker-1"), Equals, 0)
+	c.Assert(s.execCount(c, "shop-worker-2"), Equals, 1)
+	c.Assert(s.execCount(c, "shop-worker-3"), Equals, 1)
+	c.Assert(s.execCount(c, "shop-worker-run"), Equals, 0)
+
+	job.OnUnavailable = UnavailableSkip
+	c.Assert(job.Run(newContext(job)), IsNil)
+}
+
+func (s *SuiteExecJob) TestRunContainerLabel(c *C) {
+	s.buildReplicas(c)
+
+	job := &ExecJob{Client: s.client}
+	job.ContainerLabel = []string{"role=cache"}
+	job.Command = "flush"
+
+	c.Assert(job.Run(newContext(job)), ErrorMatches, "no containers matching role=cache")
+
+	job.ContainerLabel = []string{"role"}
+	job.Targets = "some"
+	c.Assert(job.Run(newContext(job)), ErrorMatches, `unknown targets "some".*`)
+}
+
+// buildReplicas creates the containers of a scaled compose service, the first
+// one is stopped, and a one-off container of the same service
+func (s *SuiteExecJob) buildReplicas(c *C) {
+	for _, name := range []string{"shop-worker-1", "shop-worker-2", "shop-worker-3", "shop-worker-run"} {
+		labels := map[string]string{
+			"role":              "worker",
+			composeProjectLabel: "shop",
+			composeServiceLabel: "worker",
+			composeOneoffLabel:  "False",
+		}
+		if name == "shop-worker-run" {
+			labels[composeOneoffLabel] = "True"
+		}
+
+		container, err := s.client.CreateContainer(docker.CreateContainerOptions{
+			Name:   name,
+			Config: &docker.Config{Image: "test", Labels: labels},
+		})
+		c.Assert(err, IsNil)
+
+		if name != "shop-worker-1" {
+			c.Assert(s.client.StartContainer(container.ID, nil), IsNil)
+		}
+	}
+}
+
+func (s *SuiteExecJob) execCount(c *C, name string) int {
+	container, err := s.client.InspectContainer(name)
+	c.Assert(err, IsNil)
+	return len(container.ExecIDs)
+}
+
 func (s *SuiteExecJob) buildContainer(c *C) {
 	inputbuf := bytes.NewBuffer(nil)
 	tr := tar.NewWriter(inputbuf)
@@ -99,9 +295,12 @@ func (s *SuiteExecJob) buildContainer(c *C) {
 	})
 	c.Assert(err, IsNil)
 
-	_, err = s.client.CreateContainer(docker.CreateContainerOptions{
+	container, err := s.client.CreateContainer(docker.CreateContainerOptions{
 		Name:   ContainerFixture,
 		Config: &docker.Config{Image: "test"},
 	})
+	c.Assert(err, IsNil)
 
+	err = s.client.StartContainer(container.ID, nil)
+	c.Assert(err, IsNil)
 }
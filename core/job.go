@@ -1,6 +1,7 @@
 package core
 
 import (
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -8,22 +9,39 @@ import (
 )
 
 type BareJob struct {
-	Schedule string
-	Name     string
-	Command  string
+	Schedule   []string
+	Name       string
+	Command    string
+	RunOnStart bool   `gcfg:"run-on-start" mapstructure:"run-on-start"`
+	Jitter     string `gcfg:"jitter" mapstructure:"jitter"`
 
 	middlewareContainer
+	jobType string
 	running int32
 	lock    sync.Mutex
 	history []*Execution
-	cronID  int
+	cronIDs []int
 }
 
 func (j *BareJob) GetName() string {
 	return j.Name
 }
 
+// SetType sets the type of the job, eg.: job-run, exposed to its executions
+func (j *BareJob) SetType(t string) {
+	j.jobType = t
+}
+
+func (j *BareJob) GetType() string {
+	return j.jobType
+}
+
+// GetSchedule returns all the schedules of the job, in a single string
 func (j *BareJob) GetSchedule() string {
+	return strings.Join(j.Schedule, ", ")
+}
+
+func (j *BareJob) GetSchedules() []string {
 	return j.Schedule
 }
 
@@ -31,12 +49,20 @@ func (j *BareJob) GetCommand() string {
 	return j.Command
 }
 
-func (j *BareJob) GetCronJobID() int {
-	return j.cronID
+func (j *BareJob) GetJitter() string {
+	return j.Jitter
+}
+
+func (j *BareJob) ShouldRunOnStart() bool {
+	return j.RunOnStart
+}
+
+func (j *BareJob) GetCronJobIDs() []int {
+	return j.cronIDs
 }
 
-func (j *BareJob) SetCronJobID(id int) {
-	j.cronID = id
+func (j *BareJob) SetCronJobIDs(ids []int) {
+	j.cronIDs = ids
 }
 
 func (j *BareJob) Running() int32 {
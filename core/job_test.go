@@ -9,15 +9,22 @@ var _ = Suite(&SuiteBareJob{})
 func (s *SuiteBareJob) TestGetters(c *C) {
 	job := &BareJob{
 		Name:     "foo",
-		Schedule: "bar",
+		Schedule: []string{"bar"},
 		Command:  "qux",
 	}
 
 	c.Assert(job.GetName(), Equals, "foo")
 	c.Assert(job.GetSchedule(), Equals, "bar")
+	c.Assert(job.GetSchedules(), DeepEquals, []string{"bar"})
 	c.Assert(job.GetCommand(), Equals, "qux")
 }
 
+func (s *SuiteBareJob) TestGetScheduleMultiple(c *C) {
+	job := &BareJob{Schedule: []string{"@daily", "@hourly"}}
+
+	c.Assert(job.GetSchedule(), Equals, "@daily, @hourly")
+}
+
 func (s *SuiteBareJob) TestNotifyStartStop(c *C) {
 	job := &BareJob{}
 
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// LabelManaged is set on every container and service created by ofelia
+	LabelManaged = "ofelia.managed"
+	// LabelInstance is the ID of the ofelia instance that created it
+	LabelInstance = "ofelia.instance"
+	// LabelJobName is the name of the job that created it
+	LabelJobName = "ofelia.job-name"
+	// LabelExecutionID is the ID of the execution that created it
+	LabelExecutionID = "ofelia.execution-id"
+	// LabelDelete is true if it has to be deleted once the execution finishes
+	LabelDelete = "ofelia.delete"
+	// LabelJobType is the type of the job that created it, eg.: job-run
+	LabelJobType = "ofelia.job-type"
+	// LabelScheduledTime is when the execution was scheduled, as RFC 3339
+	LabelScheduledTime = "ofelia.scheduled-time"
+	// LabelAttempt is the number of attempt of the execution
+	LabelAttempt = "ofelia.attempt"
+
+	// DefaultInstanceID is the instance ID used when none is configured, it
+	// has to be changed when several ofelia share the same docker daemon
+	DefaultInstanceID = "default"
+)
+
+// managedLabels returns the labels identifying the containers and services
+// created by the given execution of the given type of job, merged into the
+// given labels
+func managedLabels(ctx *Context, jobType string, labels map[string]string, delete bool) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	instance := DefaultInstanceID
+	if ctx.Scheduler != nil && ctx.Scheduler.InstanceID != "" {
+		instance = ctx.Scheduler.InstanceID
+	}
+
+	labels[LabelManaged] = "true"
+	labels[LabelInstance] = instance
+	labels[LabelDelete] = strconv.FormatBool(delete)
+	if jobType != "" {
+		labels[LabelJobType] = jobType
+	}
+
+	if ctx.Job != nil {
+		labels[LabelJobName] = ctx.Job.GetName()
+	}
+
+	if ctx.Execution != nil {
+		labels[LabelExecutionID] = ctx.Execution.ID
+		labels[LabelAttempt] = strconv.Itoa(ctx.Execution.Attempt)
+		if scheduled := ctx.Execution.scheduled(); !scheduled.IsZero() {
+			labels[LabelScheduledTime] = scheduled.Format(time.RFC3339)
+		}
+	}
+
+	return labels
+}
@@ -1,8 +1,11 @@
 package core
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
+	"time"
 
 	"github.com/gobs/args"
 )
@@ -11,6 +14,44 @@ type LocalJob struct {
 	BareJob     `mapstructure:",squash"`
 	Dir         string
 	Environment []string
+	// EnvFile are dotenv files and EnvFromHost names of variables of the host,
+	// merged with Environment, see buildEnvironment
+	EnvFile     []string `gcfg:"env-file" mapstructure:"env-file"`
+	EnvFromHost []string `gcfg:"env-from-host" mapstructure:"env-from-host"`
+	// EnvClear runs the command with only the given environment, instead of
+	// adding it to the environment of ofelia
+	EnvClear bool `gcfg:"env-clear" mapstructure:"env-clear"`
+	// Shell runs the command as `<shell> -c <command>`, so pipes and
+	// redirects can be used
+	Shell string
+	// User and Group are the user and group, by name or id, the command runs as
+	User  string
+	Group string
+	// Nice is the niceness of the process, from -20 to 19
+	Nice int
+	// Umask is the file mode creation mask of the process, in octal
+	Umask string
+	// LimitMemory, LimitCPU and LimitFiles limit the address space, the CPU
+	// time and the number of open files of the process
+	LimitMemory string `gcfg:"limit-memory" mapstructure:"limit-memory"`
+	LimitCPU    string `gcfg:"limit-cpu" mapstructure:"limit-cpu"`
+	LimitFiles  uint64 `gcfg:"limit-files" mapstructure:"limit-files"`
+}
+
+// processLimits are the limits applied to the process of a local job, zero
+// values are not applied
+type processLimits struct {
+	// umask is -1 when not set
+	umask  int
+	nice   int
+	memory uint64
+	// cpu is in seconds
+	cpu   uint64
+	files uint64
+}
+
+func (l *processLimits) isZero() bool {
+	return l.umask == -1 && l.nice == 0 && l.memory == 0 && l.cpu == 0 && l.files == 0
 }
 
 func NewLocalJob() *LocalJob {
@@ -23,24 +64,95 @@ func (j *LocalJob) Run(ctx *Context) error {
 		return err
 	}
 
-	return cmd.Run()
+	limits, err := j.processLimits()
+	if err != nil {
+		return err
+	}
+
+	if err := setProcessAttr(cmd, j.User, j.Group); err != nil {
+		return err
+	}
+
+	if err := startProcess(cmd, limits); err != nil {
+		return err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if cause := ctx.Execution.Canceled(); cause != nil {
+			return cause
+		}
+
+		return err
+	}
+
+	return nil
 }
 
 func (j *LocalJob) buildCommand(ctx *Context) (*exec.Cmd, error) {
 	args := args.GetArgs(j.Command)
+	if j.Shell != "" {
+		args = []string{j.Shell, "-c", j.Command}
+	}
+
 	bin, err := exec.LookPath(args[0])
 	if err != nil {
 		return nil, err
 	}
 
-	return &exec.Cmd{
-		Path:   bin,
-		Args:   args,
-		Stdout: ctx.Execution.OutputStream,
-		Stderr: ctx.Execution.ErrorStream,
-		// add custom env variables to the existing ones
-		// instead of overwriting them
-		Env: append(os.Environ(), j.Environment...),
-		Dir: j.Dir,
-	}, nil
+	// the process is killed if the execution is canceled
+	cmd := exec.CommandContext(ctx.Execution.Context(), bin)
+	cmd.Args = args
+	cmd.Stdout = ctx.Execution.OutputStream
+	cmd.Stderr = ctx.Execution.ErrorStream
+	env, err := buildEnvironment(j.EnvFile, j.EnvFromHost, j.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	// add custom env variables to the existing ones
+	// instead of overwriting them, unless env-clear is set
+	env = executionEnvironment(ctx, j.GetType(), env)
+	if j.EnvClear {
+		cmd.Env = env
+	} else {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	cmd.Dir = j.Dir
+
+	return cmd, nil
+}
+
+func (j *LocalJob) processLimits() (*processLimits, error) {
+	l := &processLimits{umask: -1, nice: j.Nice, files: j.LimitFiles}
+	if j.Nice < -20 || j.Nice > 19 {
+		return nil, fmt.Errorf("invalid nice %d, expected a value from -20 to 19", j.Nice)
+	}
+
+	if j.Umask != "" {
+		umask, err := strconv.ParseUint(j.Umask, 8, 32)
+		if err != nil || umask > 0o777 {
+			return nil, fmt.Errorf("invalid umask %q, expected an octal mode like 022", j.Umask)
+		}
+
+		l.umask = int(umask)
+	}
+
+	memory, err := parseMemory(j.LimitMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	l.memory = uint64(memory)
+
+	if j.LimitCPU != "" {
+		cpu, err := time.ParseDuration(j.LimitCPU)
+		if err != nil || cpu < time.Second {
+			return nil, fmt.Errorf("invalid cpu limit %q, expected a duration of at least 1s", j.LimitCPU)
+		}
+
+		l.cpu = uint64(cpu / time.Second)
+	}
+
+	return l, nil
 }
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// setProcessAttr runs the command in its own process group, killed as a whole
+// when the execution is canceled, as the given user and group
+func setProcessAttr(cmd *exec.Cmd, username, group string) error {
+	cred, err := lookupCredential(username, group)
+	if err != nil {
+		return err
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Credential: cred}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	return nil
+}
+
+// startProcess starts the command with the given limits, they are set by a
+// shell wrapping the command, before it is executed
+func startProcess(cmd *exec.Cmd, l *processLimits) error {
+	script, err := limitsScript(l)
+	if err != nil {
+		return err
+	}
+
+	if script != "" {
+		cmd.Args = append([]string{"/bin/sh", "-c", script + `"$@"`, "sh", cmd.Path}, cmd.Args[1:]...)
+		cmd.Path = "/bin/sh"
+	}
+
+	return cmd.Start()
+}
+
+// limitsScript returns the shell commands setting the limits and executing
+// the command given as arguments, chained so the command isn't executed if
+// any of them fails
+func limitsScript(l *processLimits) (string, error) {
+	if l.isZero() {
+		return "", nil
+	}
+
+	var b strings.Builder
+	if l.umask != -1 {
+		fmt.Fprintf(&b, "umask %04o && ", l.umask)
+	}
+
+	if l.memory != 0 {
+		// in KiB, rounded up
+		fmt.Fprintf(&b, "ulimit -v %d && ", (l.memory+1023)/1024)
+	}
+
+	if l.cpu != 0 {
+		fmt.Fprintf(&b, "ulimit -t %d && ", l.cpu)
+	}
+
+	if l.files != 0 {
+		fmt.Fprintf(&b, "ulimit -n %d && ", l.files)
+	}
+
+	b.WriteString("exec ")
+	if l.nice != 0 {
+		// the priority is 20 - niceness, and nice takes an increment
+		prio, err := unix.Getpriority(unix.PRIO_PROCESS, 0)
+		if err != nil {
+			return "", fmt.Errorf("error getting the niceness of ofelia: %w", err)
+		}
+
+		fmt.Fprintf(&b, "nice -n %d ", l.nice-(20-prio))
+	}
+
+	return b.String(), nil
+}
+
+// lookupCredential returns the credential of the given user and group, nil if
+// none is set. The group defaults to the primary group of the user.
+func lookupCredential(username, group string) (*syscall.Credential, error) {
+	if username == "" && group == "" {
+		return nil, nil
+	}
+
+	// the supplementary groups are kept unless the user changes
+	cred := &syscall.Credential{
+		Uid:         uint32(os.Getuid()),
+		Gid:         uint32(os.Getgid()),
+		NoSetGroups: true,
+	}
+
+	if username != "" {
+		u, err := user.Lookup(username)
+		if err != nil {
+			if u, err = user.LookupId(username); err != nil {
+				return nil, fmt.Errorf("unknown user %q", username)
+			}
+		}
+
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		cred.Uid, cred.Gid, cred.NoSetGroups = uint32(uid), uint32(gid), false
+
+		groups, _ := u.GroupIds()
+		for _, g := range groups {
+			if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+				cred.Groups = append(cred.Groups, uint32(id))
+			}
+		}
+	}
+
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return nil, fmt.Errorf("unknown group %q", group)
+			}
+		}
+
+		gid, _ := strconv.ParseUint(g.Gid, 10, 32)
+		cred.Gid = uint32(gid)
+	}
+
+	return cred, nil
+}
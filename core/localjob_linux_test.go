@@ -0,0 +1,58 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *SuiteLocalJob) TestRunCanceledKillsProcessGroup(c *C) {
+	job := &LocalJob{}
+	job.Shell = "/bin/sh"
+	job.Command = `sleep 10 & sleep 10`
+
+	e := NewExecution()
+	cause := errors.New("foo")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		e.Cancel(cause)
+	}()
+
+	// the output is only closed once the background sleep is killed too
+	start := time.Now()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, Equals, cause)
+	c.Assert(time.Since(start) < 5*time.Second, Equals, true)
+}
+
+func (s *SuiteLocalJob) TestRunLimits(c *C) {
+	job := &LocalJob{Nice: 5, Umask: "027", LimitFiles: 64, LimitCPU: "1m", LimitMemory: "1g"}
+	job.Shell = "/bin/sh"
+	job.Command = `ulimit -n; ulimit -t; ulimit -v; umask; cut -d " " -f 19 /proc/$$/stat`
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, IsNil)
+	c.Assert(e.OutputStream.String(), Equals, "64\n60\n1048576\n0027\n5\n")
+}
+
+func (s *SuiteLocalJob) TestRunLimitsBeforeExec(c *C) {
+	job := &LocalJob{Nice: 7, Umask: "077", LimitFiles: 32}
+	job.Shell = "/bin/sh"
+	// the children started right away get the limits too
+	job.Command = `cat /proc/self/limits /proc/self/status; cut -d " " -f 19 /proc/self/stat`
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, IsNil)
+	c.Assert(e.OutputStream.String(), Matches, `(?s).*Max open files\s+32\s+32.*Umask:\s+0077.*\n7\n`)
+}
+
+func (s *SuiteLocalJob) TestRunUnknownUser(c *C) {
+	job := &LocalJob{User: "ofelia-missing-user"}
+	job.Command = `true`
+
+	err := job.Run(&Context{Execution: NewExecution()})
+	c.Assert(err, ErrorMatches, `unknown user "ofelia-missing-user"`)
+}
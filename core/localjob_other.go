@@ -0,0 +1,25 @@
+//go:build !linux
+
+package core
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+func setProcessAttr(cmd *exec.Cmd, username, group string) error {
+	if username != "" || group != "" {
+		return fmt.Errorf("user and group are not supported on %s", runtime.GOOS)
+	}
+
+	return nil
+}
+
+func startProcess(cmd *exec.Cmd, l *processLimits) error {
+	if !l.isZero() {
+		return fmt.Errorf("nice, umask and limits are not supported on %s", runtime.GOOS)
+	}
+
+	return cmd.Start()
+}
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/armon/circbuf"
 
@@ -50,3 +53,75 @@ func (s *SuiteLocalJob) TestEnvironment(c *C) {
 		c.Assert(found, Equals, true)
 	}
 }
+
+func (s *SuiteLocalJob) TestRunCanceled(c *C) {
+	job := &LocalJob{}
+	job.Command = `sleep 10`
+
+	e := NewExecution()
+	cause := errors.New("foo")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		e.Cancel(cause)
+	}()
+
+	start := time.Now()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, Equals, cause)
+	c.Assert(time.Since(start) < 5*time.Second, Equals, true)
+}
+
+func (s *SuiteLocalJob) TestRunShell(c *C) {
+	job := &LocalJob{}
+	job.Shell = "/bin/sh"
+	job.Command = `echo foo | tr a-z A-Z`
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, IsNil)
+	c.Assert(e.OutputStream.String(), Equals, "FOO\n")
+}
+
+func (s *SuiteLocalJob) TestEnvClear(c *C) {
+	job := &LocalJob{}
+	job.Command = `env`
+	job.Environment = []string{"FOO=bar"}
+	job.EnvClear = true
+	job.SetType("job-local")
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, IsNil)
+	c.Assert(e.OutputStream.String(), Equals, fmt.Sprintf(
+		"OFELIA_JOB_TYPE=job-local\nOFELIA_EXECUTION_ID=%s\nOFELIA_ATTEMPT=1\nFOO=bar\n", e.ID,
+	))
+}
+
+func (s *SuiteLocalJob) TestEnvFile(c *C) {
+	job := &LocalJob{}
+	job.Command = `env`
+	job.EnvFile = []string{writeEnvFile(c, "FOO=file\nBAR=file\n")}
+	job.Environment = []string{"FOO=bar"}
+	job.EnvClear = true
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e})
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasSuffix(e.OutputStream.String(), "\nFOO=bar\nBAR=file\n"), Equals, true)
+}
+
+func (s *SuiteLocalJob) TestProcessLimits(c *C) {
+	job := &LocalJob{Nice: 10, Umask: "027", LimitMemory: "1g", LimitCPU: "1m", LimitFiles: 64}
+	l, err := job.processLimits()
+	c.Assert(err, IsNil)
+	c.Assert(*l, Equals, processLimits{umask: 0o27, nice: 10, memory: 1 << 30, cpu: 60, files: 64})
+
+	_, err = (&LocalJob{Umask: "999"}).processLimits()
+	c.Assert(err, ErrorMatches, `invalid umask "999".*`)
+
+	_, err = (&LocalJob{Nice: 20}).processLimits()
+	c.Assert(err, ErrorMatches, `invalid nice 20.*`)
+
+	_, err = (&LocalJob{LimitCPU: "10ms"}).processLimits()
+	c.Assert(err, ErrorMatches, `invalid cpu limit "10ms".*`)
+}
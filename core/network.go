@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// networkAttachment is a network a container is connected to, and the
+// configuration of the container endpoint in it
+type networkAttachment struct {
+	name     string
+	endpoint *docker.EndpointConfig
+}
+
+// parseNetworks parses a list of networks, every network is a name or a
+// list of options as `docker run --network`, eg.:
+// `name=db,alias=etl,ip=172.20.0.5,ip6=2001:db8::5`
+func parseNetworks(values []string) ([]networkAttachment, error) {
+	var networks []networkAttachment
+	for _, v := range values {
+		n := networkAttachment{endpoint: &docker.EndpointConfig{}}
+		if !strings.Contains(v, "=") {
+			n.name = strings.TrimSpace(v)
+		} else if err := n.parseOptions(v); err != nil {
+			return nil, err
+		}
+
+		if n.name == "" {
+			return nil, fmt.Errorf("invalid network %q, missing name", v)
+		}
+
+		networks = append(networks, n)
+	}
+
+	return networks, nil
+}
+
+func (n *networkAttachment) parseOptions(value string) error {
+	for _, option := range strings.Split(value, ",") {
+		key, v, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "name":
+			n.name = v
+		case "alias":
+			n.endpoint.Aliases = append(n.endpoint.Aliases, v)
+		case "ip":
+			n.ipam().IPv4Address = v
+		case "ip6":
+			n.ipam().IPv6Address = v
+		default:
+			return fmt.Errorf("invalid network %q, unknown option %q", value, key)
+		}
+	}
+
+	return nil
+}
+
+func (n *networkAttachment) ipam() *docker.EndpointIPAMConfig {
+	if n.endpoint.IPAMConfig == nil {
+		n.endpoint.IPAMConfig = &docker.EndpointIPAMConfig{}
+	}
+
+	return n.endpoint.IPAMConfig
+}
+
+// isolatedNetworkMode returns true if a container with the given network mode
+// can't be connected to other networks
+func isolatedNetworkMode(mode string) bool {
+	return mode == "host" || mode == "none" || strings.HasPrefix(mode, "container:")
+}
+
+// lookupNetwork returns the network with the given name or ID, the name
+// filter of the API also returns the networks partially matching the name.
+func lookupNetwork(client *docker.Client, name string) (*docker.Network, error) {
+	networks, err := client.FilteredListNetworks(docker.NetworkFilterOpts{
+		"name": map[string]bool{name: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error looking up network %q: %w", name, err)
+	}
+
+	for i := range networks {
+		if networks[i].Name == name || networks[i].ID == name {
+			return &networks[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("network %q not found", name)
+}
+
+// parsePorts parses a list of published ports as `docker run --publish`,
+// eg.: `8080:80`, `127.0.0.1:5432:5432` or `53:53/udp`
+func parsePorts(values []string) (map[docker.Port]struct{}, map[docker.Port][]docker.PortBinding, error) {
+	if len(values) == 0 {
+		return nil, nil, nil
+	}
+
+	exposed, bindings, err := nat.ParsePortSpecs(values)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid published port: %w", err)
+	}
+
+	ports := make(map[docker.Port]struct{}, len(exposed))
+	for p := range exposed {
+		ports[docker.Port(p)] = struct{}{}
+	}
+
+	portBindings := make(map[docker.Port][]docker.PortBinding, len(bindings))
+	for p, bs := range bindings {
+		for _, b := range bs {
+			portBindings[docker.Port(p)] = append(portBindings[docker.Port(p)], docker.PortBinding{
+				HostIP:   b.HostIP,
+				HostPort: b.HostPort,
+			})
+		}
+	}
+
+	return ports, portBindings, nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	docker "github.com/fsouza/go-dockerclient"
+	. "gopkg.in/check.v1"
+)
+
+type SuiteNetwork struct{}
+
+var _ = Suite(&SuiteNetwork{})
+
+func (s *SuiteNetwork) TestParseNetworks(c *C) {
+	networks, err := parseNetworks([]string{
+		"storage",
+		"name=db,alias=etl,alias=worker,ip=172.20.0.5,ip6=2001:db8::5",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(networks, HasLen, 2)
+
+	c.Assert(networks[0].name, Equals, "storage")
+	c.Assert(networks[0].endpoint, DeepEquals, &docker.EndpointConfig{})
+
+	c.Assert(networks[1].name, Equals, "db")
+	c.Assert(networks[1].endpoint, DeepEquals, &docker.EndpointConfig{
+		Aliases: []string{"etl", "worker"},
+		IPAMConfig: &docker.EndpointIPAMConfig{
+			IPv4Address: "172.20.0.5",
+			IPv6Address: "2001:db8::5",
+		},
+	})
+}
+
+func (s *SuiteNetwork) TestParseNetworksInvalid(c *C) {
+	_, err := parseNetworks([]string{"alias=foo"})
+	c.Assert(err, ErrorMatches, ".*missing name")
+
+	_, err = parseNetworks([]string{"name=foo,mac=bar"})
+	c.Assert(err, ErrorMatches, `.*unknown option "mac"`)
+}
+
+func (s *SuiteNetwork) TestParsePorts(c *C) {
+	exposed, bindings, err := parsePorts([]string{"8080:80", "127.0.0.1:5432:5432", "53:53/udp"})
+	c.Assert(err, IsNil)
+	c.Assert(exposed, DeepEquals, map[docker.Port]struct{}{
+		"80/tcp":   {},
+		"5432/tcp": {},
+		"53/udp":   {},
+	})
+	c.Assert(bindings, DeepEquals, map[docker.Port][]docker.PortBinding{
+		"80/tcp":   {{HostPort: "8080"}},
+		"5432/tcp": {{HostIP: "127.0.0.1", HostPort: "5432"}},
+		"53/udp":   {{HostPort: "53"}},
+	})
+
+	_, _, err = parsePorts([]string{"foo"})
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteNetwork) TestIsolatedNetworkMode(c *C) {
+	c.Assert(isolatedNetworkMode("host"), Equals, true)
+	c.Assert(isolatedNetworkMode("none"), Equals, true)
+	c.Assert(isolatedNetworkMode("container:db"), Equals, true)
+	c.Assert(isolatedNetworkMode("bridge"), Equals, false)
+	c.Assert(isolatedNetworkMode(""), Equals, false)
+}
@@ -0,0 +1,252 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	units "github.com/docker/go-units"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// parseMemory parses a memory size as `docker run --memory`, eg.: `512m`
+func parseMemory(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	size, err := units.RAMInBytes(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory size %q: %w", value, err)
+	}
+
+	return size, nil
+}
+
+// parseMemorySwap parses a memory plus swap size as `docker run
+// --memory-swap`, where -1 is unlimited swap
+func parseMemorySwap(value string) (int64, error) {
+	if value == "-1" {
+		return -1, nil
+	}
+
+	return parseMemory(value)
+}
+
+// parseCPUs parses a number of CPUs as `docker run --cpus`, eg.: `1.5`,
+// returning it in units of 1e-9 CPUs
+func parseCPUs(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	cpus, err := strconv.ParseFloat(value, 64)
+	if err != nil || cpus < 0 {
+		return 0, fmt.Errorf("invalid number of cpus %q", value)
+	}
+
+	return int64(cpus * 1e9), nil
+}
+
+// parseKeyValues parses a list of `key=value` strings, eg.: labels
+func parseKeyValues(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	m := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, _ := strings.Cut(v, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid key value %q", v)
+		}
+
+		m[key] = value
+	}
+
+	return m, nil
+}
+
+// parseTmpfs parses a list of tmpfs mounts as `docker run --tmpfs`,
+// eg.: `/run:rw,size=64m`
+func parseTmpfs(values []string) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, len(values))
+	for _, v := range values {
+		path, options, _ := strings.Cut(v, ":")
+		m[path] = options
+	}
+
+	return m
+}
+
+// parseDevices parses a list of devices as `docker run --device`,
+// eg.: `/dev/fuse`, `/dev/sda:/dev/xvda:rwm`
+func parseDevices(values []string) ([]docker.Device, error) {
+	var devices []docker.Device
+	for _, v := range values {
+		parts := strings.Split(v, ":")
+		if len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid device %q", v)
+		}
+
+		d := docker.Device{
+			PathOnHost:        parts[0],
+			PathInContainer:   parts[0],
+			CgroupPermissions: "rwm",
+		}
+
+		if len(parts) > 1 && parts[1] != "" {
+			d.PathInContainer = parts[1]
+		}
+
+		if len(parts) > 2 {
+			d.CgroupPermissions = parts[2]
+		}
+
+		devices = append(devices, d)
+	}
+
+	return devices, nil
+}
+
+// parseUlimits parses a list of ulimits as `docker run --ulimit`,
+// eg.: `nofile=1024:2048` or `nproc=512`
+func parseUlimits(values []string) ([]docker.ULimit, error) {
+	var ulimits []docker.ULimit
+	for _, v := range values {
+		u, err := units.ParseUlimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ulimit %q: %w", v, err)
+		}
+
+		ulimits = append(ulimits, docker.ULimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard})
+	}
+
+	return ulimits, nil
+}
+
+// parseCSVOptions parses comma separated options as `docker service create
+// --mount`, eg.: `type=bind,source=/data,target=/data,readonly`, the options
+// without value are set to "true"
+func parseCSVOptions(value string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, o := range strings.Split(value, ",") {
+		key, v, ok := strings.Cut(strings.TrimSpace(o), "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid option %q in %q", o, value)
+		}
+
+		if !ok {
+			v = "true"
+		}
+
+		options[strings.ToLower(key)] = v
+	}
+
+	return options, nil
+}
+
+// parseMounts parses a list of mounts as `docker service create --mount`,
+// eg.: `type=volume,source=cache,target=/cache`
+func parseMounts(values []string) ([]mount.Mount, error) {
+	var mounts []mount.Mount
+	for _, v := range values {
+		options, err := parseCSVOptions(v)
+		if err != nil {
+			return nil, err
+		}
+
+		m := mount.Mount{Type: mount.TypeVolume}
+		for key, value := range options {
+			switch key {
+			case "type":
+				m.Type = mount.Type(value)
+			case "source", "src":
+				m.Source = value
+			case "target", "destination", "dst":
+				m.Target = value
+			case "readonly", "ro":
+				if m.ReadOnly, err = strconv.ParseBool(value); err != nil {
+					return nil, fmt.Errorf("invalid readonly option in mount %q", v)
+				}
+			default:
+				return nil, fmt.Errorf("unknown option %q in mount %q", key, v)
+			}
+		}
+
+		switch m.Type {
+		case mount.TypeBind, mount.TypeVolume, mount.TypeTmpfs:
+		default:
+			return nil, fmt.Errorf("invalid type %q in mount %q", m.Type, v)
+		}
+
+		if m.Target == "" {
+			return nil, fmt.Errorf("missing target in mount %q", v)
+		}
+
+		mounts = append(mounts, m)
+	}
+
+	return mounts, nil
+}
+
+// fileReference is a secret or config given to a service, as `docker service
+// create --secret`, eg.: `db-password` or `source=db-password,target=db,mode=0400`
+type fileReference struct {
+	Name string
+	File swarm.SecretReferenceFileTarget
+}
+
+func parseFileReference(value string) (fileReference, error) {
+	if !strings.Contains(value, "=") {
+		return fileReference{
+			Name: value,
+			File: swarm.SecretReferenceFileTarget{Name: value, UID: "0", GID: "0", Mode: 0444},
+		}, nil
+	}
+
+	options, err := parseCSVOptions(value)
+	if err != nil {
+		return fileReference{}, err
+	}
+
+	ref := fileReference{File: swarm.SecretReferenceFileTarget{UID: "0", GID: "0", Mode: 0444}}
+	for key, v := range options {
+		switch key {
+		case "source", "src":
+			ref.Name = v
+		case "target":
+			ref.File.Name = v
+		case "uid":
+			ref.File.UID = v
+		case "gid":
+			ref.File.GID = v
+		case "mode":
+			mode, err := strconv.ParseUint(v, 8, 32)
+			if err != nil {
+				return ref, fmt.Errorf("invalid mode %q in %q", v, value)
+			}
+
+			ref.File.Mode = os.FileMode(mode)
+		default:
+			return ref, fmt.Errorf("unknown option %q in %q", key, value)
+		}
+	}
+
+	if ref.Name == "" {
+		return ref, fmt.Errorf("missing source in %q", value)
+	}
+
+	if ref.File.Name == "" {
+		ref.File.Name = ref.Name
+	}
+
+	return ref, nil
+}
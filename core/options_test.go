@@ -0,0 +1,125 @@
+package core
+
+import (
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/swarm"
+	docker "github.com/fsouza/go-dockerclient"
+	. "gopkg.in/check.v1"
+)
+
+type SuiteOptions struct{}
+
+var _ = Suite(&SuiteOptions{})
+
+func (s *SuiteOptions) TestParseMemory(c *C) {
+	m, err := parseMemory("512m")
+	c.Assert(err, IsNil)
+	c.Assert(m, Equals, int64(512*1024*1024))
+
+	m, err = parseMemory("")
+	c.Assert(err, IsNil)
+	c.Assert(m, Equals, int64(0))
+
+	_, err = parseMemory("foo")
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteOptions) TestParseMemorySwap(c *C) {
+	m, err := parseMemorySwap("-1")
+	c.Assert(err, IsNil)
+	c.Assert(m, Equals, int64(-1))
+
+	m, err = parseMemorySwap("1g")
+	c.Assert(err, IsNil)
+	c.Assert(m, Equals, int64(1024*1024*1024))
+
+	_, err = parseMemorySwap("-2")
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteOptions) TestParseCPUs(c *C) {
+	cpus, err := parseCPUs("1.5")
+	c.Assert(err, IsNil)
+	c.Assert(cpus, Equals, int64(1500000000))
+
+	_, err = parseCPUs("-1")
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteOptions) TestParseKeyValues(c *C) {
+	m, err := parseKeyValues([]string{"foo=bar", "qux=", "baz=a=b"})
+	c.Assert(err, IsNil)
+	c.Assert(m, DeepEquals, map[string]string{"foo": "bar", "qux": "", "baz": "a=b"})
+
+	_, err = parseKeyValues([]string{"=bar"})
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteOptions) TestParseTmpfs(c *C) {
+	c.Assert(parseTmpfs([]string{"/run:rw,size=64m", "/tmp"}), DeepEquals, map[string]string{
+		"/run": "rw,size=64m",
+		"/tmp": "",
+	})
+}
+
+func (s *SuiteOptions) TestParseDevices(c *C) {
+	d, err := parseDevices([]string{"/dev/fuse", "/dev/sda:/dev/xvda:r"})
+	c.Assert(err, IsNil)
+	c.Assert(d, DeepEquals, []docker.Device{
+		{PathOnHost: "/dev/fuse", PathInContainer: "/dev/fuse", CgroupPermissions: "rwm"},
+		{PathOnHost: "/dev/sda", PathInContainer: "/dev/xvda", CgroupPermissions: "r"},
+	})
+
+	_, err = parseDevices([]string{"a:b:c:d"})
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteOptions) TestParseUlimits(c *C) {
+	u, err := parseUlimits([]string{"nofile=1024:2048", "nproc=512"})
+	c.Assert(err, IsNil)
+	c.Assert(u, DeepEquals, []docker.ULimit{
+		{Name: "nofile", Soft: 1024, Hard: 2048},
+		{Name: "nproc", Soft: 512, Hard: 512},
+	})
+
+	_, err = parseUlimits([]string{"foo"})
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteOptions) TestParseMounts(c *C) {
+	m, err := parseMounts([]string{
+		"type=bind,source=/srv/backups,target=/backups,readonly",
+		"source=cache,dst=/cache",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(m, DeepEquals, []mount.Mount{
+		{Type: mount.TypeBind, Source: "/srv/backups", Target: "/backups", ReadOnly: true},
+		{Type: mount.TypeVolume, Source: "cache", Target: "/cache"},
+	})
+
+	for _, invalid := range []string{"source=cache", "type=foo,target=/foo", "target=/foo,bar=qux", "target=/foo,ro=maybe"} {
+		_, err = parseMounts([]string{invalid})
+		c.Assert(err, NotNil)
+	}
+}
+
+func (s *SuiteOptions) TestParseFileReference(c *C) {
+	ref, err := parseFileReference("db-password")
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, fileReference{
+		Name: "db-password",
+		File: swarm.SecretReferenceFileTarget{Name: "db-password", UID: "0", GID: "0", Mode: 0444},
+	})
+
+	ref, err = parseFileReference("source=db-password,target=db,uid=100,mode=0400")
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, fileReference{
+		Name: "db-password",
+		File: swarm.SecretReferenceFileTarget{Name: "db", UID: "100", GID: "0", Mode: 0400},
+	})
+
+	for _, invalid := range []string{"target=db", "source=db,mode=999", "source=db,foo=bar"} {
+		_, err = parseFileReference(invalid)
+		c.Assert(err, NotNil)
+	}
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// PullAlways pulls the image before every execution
+	PullAlways = "always"
+	// PullIfNotPresent pulls the image only if it is not found locally
+	PullIfNotPresent = "if-not-present"
+	// PullNever never pulls the image, it must be found locally
+	PullNever = "never"
+
+	// pullIntervalPrefix is the prefix of the interval policy, eg.:
+	// `interval:6h` pulls the image at most once every 6 hours
+	pullIntervalPrefix = "interval:"
+)
+
+// pullPolicy is a parsed pull policy
+type pullPolicy struct {
+	mode     string
+	interval time.Duration
+}
+
+// parsePullPolicy parses the given pull policy, when it is empty the legacy
+// boolean pull option is used, `true` as always and `false` as
+// if-not-present.
+func parsePullPolicy(policy, pull string) (pullPolicy, error) {
+	if policy == "" {
+		if v, err := strconv.ParseBool(pull); err == nil && !v {
+			return pullPolicy{mode: PullIfNotPresent}, nil
+		}
+
+		return pullPolicy{mode: PullAlways}, nil
+	}
+
+	switch policy {
+	case PullAlways, PullIfNotPresent, PullNever:
+		return pullPolicy{mode: policy}, nil
+	}
+
+	if strings.HasPrefix(policy, pullIntervalPrefix) {
+		d, err := time.ParseDuration(strings.TrimPrefix(policy, pullIntervalPrefix))
+		if err == nil && d > 0 {
+			return pullPolicy{mode: pullIntervalPrefix, interval: d}, nil
+		}
+	}
+
+	return pullPolicy{}, fmt.Errorf(
+		"invalid pull policy %q, expected %s, %s, %s or %s<duration>",
+		policy, PullAlways, PullIfNotPresent, PullNever, pullIntervalPrefix,
+	)
+}
+
+// pulls records the successful pulls, shared by all the jobs, so the same
+// image is not pulled concurrently nor more often than the pull policies
+// require.
+var pulls = &pullCache{images: make(map[string]*pulledImage)}
+
+type pullCache struct {
+	sync.Mutex
+	images map[string]*pulledImage
+}
+
+type pulledImage struct {
+	sync.Mutex
+	date time.Time
+}
+
+func (c *pullCache) get(image, platform string) *pulledImage {
+	c.Lock()
+	defer c.Unlock()
+
+	key := image + "@" + platform
+	if _, ok := c.images[key]; !ok {
+		c.images[key] = &pulledImage{}
+	}
+
+	return c.images[key]
+}
+
+// ensureImage makes sure the given image is available following the given
+// pull policy, registryAuth is the docker config file with the credentials
+// of the registry, by default the one of the user running ofelia
+func ensureImage(ctx *Context, client *docker.Client, image, platform, registryAuth string, p pullPolicy) error {
+	switch p.mode {
+	case PullNever:
+		return searchLocalImage(client, image)
+	case PullIfNotPresent:
+		if searchLocalImage(client, image) == nil {
+			return nil
+		}
+	}
+
+	pulled := pulls.get(image, platform)
+	requested := time.Now()
+
+	pulled.Lock()
+	defer pulled.Unlock()
+
+	// another job pulled the image while this one was waiting, or recently
+	// enough for the interval policy
+	skip := pulled.date.After(requested)
+	if p.mode == pullIntervalPrefix && time.Since(pulled.date) < p.interval {
+		skip = true
+	}
+
+	if skip && searchLocalImage(client, image) == nil {
+		return nil
+	}
+
+	err := pullImage(ctx, client, image, platform, registryAuth)
+	if err == nil {
+		pulled.date = time.Now()
+		ctx.Log("Pulled image " + image)
+		return nil
+	}
+
+	if p.mode == PullIfNotPresent || searchLocalImage(client, image) != nil {
+		return err
+	}
+
+	ctx.Warn(err.Error() + ", using local image")
+	return nil
+}
+
+func searchLocalImage(client *docker.Client, image string) error {
+	imgs, err := client.ListImages(buildFindLocalImageOptions(image))
+	if err != nil {
+		return err
+	}
+
+	if len(imgs) != 1 {
+		return ErrLocalImageNotFound
+	}
+
+	return nil
+}
+
+func pullImage(ctx *Context, client *docker.Client, image, platform, registryAuth string) error {
+	o := buildPullOptions(image)
+	o.Platform = platform
+	o.RawJSONStream = true
+
+	progress := &pullProgress{ctx: ctx, image: image}
+	o.OutputStream = progress
+
+	a, err := lookupAuth(registryAuth, o.Registry)
+	if err != nil {
+		if registryAuth != "" {
+			return err
+		}
+
+		ctx.Warn(err.Error() + ", pulling without credentials")
+	}
+
+	if err := client.PullImage(o, a); err != nil {
+		return fmt.Errorf("error pulling image %q: %s", image, err)
+	}
+
+	return nil
+}
+
+// pullProgress logs the progress of a pull, it receives the JSON messages of
+// the API and logs every change of status of the image and its layers,
+// ignoring the download and extraction progress updates.
+type pullProgress struct {
+	ctx   *Context
+	image string
+	buf   []byte
+}
+
+type pullMessage struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress string `json:"progress"`
+}
+
+func (p *pullProgress) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i == -1 {
+			break
+		}
+
+		p.log(p.buf[:i])
+		p.buf = p.buf[i+1:]
+	}
+
+	return len(b), nil
+}
+
+func (p *pullProgress) log(line []byte) {
+	var m pullMessage
+	if err := json.Unmarshal(line, &m); err != nil || m.Status == "" || m.Progress != "" {
+		return
+	}
+
+	msg := m.Status
+	if m.ID != "" {
+		msg = m.ID + ": " + msg
+	}
+
+	p.ctx.Log(fmt.Sprintf("Pulling image %s, %s", p.image, msg))
+}
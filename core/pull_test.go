@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/fsouza/go-dockerclient/testing"
+	logging "github.com/op/go-logging"
+	. "gopkg.in/check.v1"
+)
+
+type SuitePull struct {
+	server *testing.DockerServer
+	client *docker.Client
+	pulls  int
+}
+
+var _ = Suite(&SuitePull{})
+
+func (s *SuitePull) SetUpTest(c *C) {
+	var err error
+	s.server, err = testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, IsNil)
+
+	s.client, err = docker.NewClient(s.server.URL())
+	c.Assert(err, IsNil)
+
+	s.pulls = 0
+	s.server.CustomHandler("/images/create", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.pulls++
+		fmt.Fprintln(w, `{"status":"Pulling from library/foo","id":"latest"}`)
+		fmt.Fprintln(w, `{"status":"Downloading","progress":"[=>  ]","id":"a1b2"}`)
+		fmt.Fprintln(w, `{"status":"Pull complete","id":"a1b2"}`)
+	}))
+
+	pulls = &pullCache{images: make(map[string]*pulledImage)}
+}
+
+func (s *SuitePull) TestParsePullPolicy(c *C) {
+	testCases := []struct {
+		policy, pull string
+		expected     pullPolicy
+	}{
+		{"", "", pullPolicy{mode: PullAlways}},
+		{"", "true", pullPolicy{mode: PullAlways}},
+		{"", "false", pullPolicy{mode: PullIfNotPresent}},
+		{PullNever, "true", pullPolicy{mode: PullNever}},
+		{PullIfNotPresent, "", pullPolicy{mode: PullIfNotPresent}},
+		{"interval:6h", "", pullPolicy{mode: pullIntervalPrefix, interval: 6 * time.Hour}},
+	}
+
+	for _, t := range testCases {
+		p, err := parsePullPolicy(t.policy, t.pull)
+		c.Assert(err, IsNil)
+		c.Assert(p, DeepEquals, t.expected)
+	}
+
+	for _, policy := range []string{"foo", "interval:", "interval:-1h"} {
+		_, err := parsePullPolicy(policy, "")
+		c.Assert(err, NotNil)
+	}
+}
+
+func (s *SuitePull) TestEnsureImageNever(c *C) {
+	err := ensureImage(newContext(&RunJob{}), s.client, "foo", "", "", pullPolicy{mode: PullNever})
+	c.Assert(err, Equals, ErrLocalImageNotFound)
+	c.Assert(s.pulls, Equals, 0)
+}
+
+func (s *SuitePull) TestEnsureImageIfNotPresent(c *C) {
+	s.buildImage(c, "foo")
+
+	err := ensureImage(newContext(&RunJob{}), s.client, "foo", "", "", pullPolicy{mode: PullIfNotPresent})
+	c.Assert(err, IsNil)
+	c.Assert(s.pulls, Equals, 0)
+}
+
+func (s *SuitePull) TestEnsureImageAlways(c *C) {
+	s.buildImage(c, "foo")
+
+	for i := 0; i < 2; i++ {
+		err := ensureImage(newContext(&RunJob{}), s.client, "foo", "", "", pullPolicy{mode: PullAlways})
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(s.pulls, Equals, 2)
+}
+
+func (s *SuitePull) TestEnsureImageInterval(c *C) {
+	s.buildImage(c, "foo")
+
+	p := pullPolicy{mode: pullIntervalPrefix, interval: time.Hour}
+	for i := 0; i < 3; i++ {
+		err := ensureImage(newContext(&RunJob{}), s.client, "foo", "", "", p)
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(s.pulls, Equals, 1)
+
+	// the cache is per image and platform
+	err := ensureImage(newContext(&RunJob{}), s.client, "foo", "linux/arm64", "", p)
+	c.Assert(err, IsNil)
+	c.Assert(s.pulls, Equals, 2)
+}
+
+func (s *SuitePull) TestPullProgress(c *C) {
+	backend := logging.NewMemoryBackend(10)
+	logger := logging.MustGetLogger("pull-progress")
+	logger.SetBackend(logging.AddModuleLevel(backend))
+
+	ctx := newContext(&RunJob{})
+	ctx.Logger = logger
+
+	c.Assert(pullImage(ctx, s.client, "foo", "", ""), IsNil)
+
+	var lines []string
+	for n := backend.Head(); n != nil; n = n.Next() {
+		lines = append(lines, n.Record.Message())
+	}
+
+	c.Assert(lines, HasLen, 2)
+	c.Assert(lines[0], Matches, ".*Pulling image foo, latest: Pulling from library/foo$")
+	c.Assert(lines[1], Matches, ".*Pulling image foo, a1b2: Pull complete$")
+}
+
+func (s *SuitePull) buildImage(c *C, name string) {
+	(&SuiteRunJob{client: s.client}).buildImageNamed(c, name)
+}
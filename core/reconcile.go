@@ -0,0 +1,246 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+const (
+	// OrphanReattach waits for the running orphans to finish and deletes the
+	// finished ones, if their job was configured to delete them
+	OrphanReattach = "reattach"
+	// OrphanRemove removes all the orphans, even if they are still running
+	OrphanRemove = "remove"
+	// OrphanKeep leaves the orphans untouched
+	OrphanKeep = "keep"
+
+	reconcileInterval = time.Second
+
+	// swarmTaskLabel is set on the containers of the swarm tasks, they are
+	// owned by swarm and reconciled through their service
+	swarmTaskLabel = "com.docker.swarm.task.id"
+)
+
+// Reconciler looks for the containers and services left behind by a previous
+// run of an ofelia instance, eg.: after a crash, and deals with them
+// following the orphan policy
+type Reconciler struct {
+	Client     *docker.Client
+	Logger     Logger
+	InstanceID string
+	Policy     string
+}
+
+// Run looks for the orphans, the running ones are watched in background when
+// the policy is reattach. Only an invalid policy is returned as error, a
+// failure listing the orphans is logged, so it doesn't prevent the startup.
+func (r *Reconciler) Run() error {
+	switch r.Policy {
+	case OrphanKeep:
+		return nil
+	case "", OrphanReattach, OrphanRemove:
+	default:
+		return fmt.Errorf("unknown orphan policy %q", r.Policy)
+	}
+
+	if err := r.reconcileContainers(); err != nil {
+		r.Logger.Warningf("Failed to reconcile orphaned containers: %s", err)
+	}
+
+	// the services are reconciled even if no job-service-run is configured
+	// anymore, only the swarm managers can list them
+	manager, err := r.isSwarmManager()
+	if err != nil {
+		r.Logger.Warningf("Failed to reconcile orphaned services: %s", err)
+	} else if manager {
+		if err := r.reconcileServices(); err != nil {
+			r.Logger.Warningf("Failed to reconcile orphaned services: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) isSwarmManager() (bool, error) {
+	info, err := r.Client.Info()
+	if err != nil {
+		return false, fmt.Errorf("error getting the docker info: %w", err)
+	}
+
+	return info.Swarm.ControlAvailable, nil
+}
+
+func (r *Reconciler) filters() map[string][]string {
+	instance := r.InstanceID
+	if instance == "" {
+		instance = DefaultInstanceID
+	}
+
+	return map[string][]string{
+		"label": {LabelManaged + "=true", LabelInstance + "=" + instance},
+	}
+}
+
+func (r *Reconciler) reconcileContainers() error {
+	containers, err := r.Client.ListContainers(docker.ListContainersOptions{
+		All:     true,
+		Filters: r.filters(),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing orphaned containers: %w", err)
+	}
+
+	for _, c := range containers {
+		if _, ok := c.Labels[swarmTaskLabel]; ok {
+			continue
+		}
+
+		o := orphan{r: r, id: c.ID, labels: c.Labels}
+		switch {
+		case r.Policy == OrphanRemove:
+			o.log("Removing orphaned container %s", c.ID)
+			o.removeContainer()
+		case c.State == "running":
+			o.log("Reattaching to orphaned container %s", c.ID)
+			go o.watchContainer()
+		default:
+			o.finishContainer()
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) reconcileServices() error {
+	services, err := r.Client.ListServices(docker.ListServicesOptions{
+		Filters: r.filters(),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing orphaned services: %w", err)
+	}
+
+	for _, s := range services {
+		o := orphan{r: r, id: s.ID, labels: s.Spec.Labels}
+		if r.Policy == OrphanRemove {
+			o.log("Removing orphaned service %s", s.ID)
+			o.removeService()
+			continue
+		}
+
+		o.log("Reattaching to orphaned service %s", s.ID)
+		go o.watchService()
+	}
+
+	return nil
+}
+
+// orphan is a container or a service of an execution that was running when
+// its ofelia instance was stopped
+type orphan struct {
+	r      *Reconciler
+	id     string
+	labels map[string]string
+}
+
+func (o *orphan) log(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	o.r.Logger.Noticef(logPrefix, o.labels[LabelJobName], o.labels[LabelExecutionID], msg)
+}
+
+func (o *orphan) warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	o.r.Logger.Warningf(logPrefix, o.labels[LabelJobName], o.labels[LabelExecutionID], msg)
+}
+
+func (o *orphan) delete() bool {
+	return o.labels[LabelDelete] == "true"
+}
+
+func (o *orphan) watchContainer() {
+	exitCode, err := o.r.Client.WaitContainer(o.id)
+	if err != nil {
+		o.warn("Failed to wait for orphaned container %s: %s", o.id, err)
+		return
+	}
+
+	o.log("Orphaned container %s finished with exit code %d", o.id, exitCode)
+	if o.delete() {
+		o.removeContainer()
+	}
+}
+
+func (o *orphan) finishContainer() {
+	c, err := o.r.Client.InspectContainer(o.id)
+	if err != nil {
+		o.warn("Failed to inspect orphaned container %s: %s", o.id, err)
+		return
+	}
+
+	o.log("Orphaned container %s finished with exit code %d", o.id, c.State.ExitCode)
+	if o.delete() {
+		o.removeContainer()
+	}
+}
+
+func (o *orphan) removeContainer() {
+	if err := o.r.Client.RemoveContainer(docker.RemoveContainerOptions{
+		ID:    o.id,
+		Force: true,
+	}); err != nil {
+		o.warn("Failed to remove orphaned container %s: %s", o.id, err)
+	}
+}
+
+func (o *orphan) watchService() {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		done, err := o.serviceDone()
+		if err != nil {
+			o.warn("Failed to watch orphaned service %s: %s", o.id, err)
+			return
+		}
+
+		if done {
+			break
+		}
+
+		<-ticker.C
+	}
+
+	o.log("Orphaned service %s finished", o.id)
+	if o.delete() {
+		o.removeService()
+	}
+}
+
+// serviceDone returns true when all the tasks of the service are finished
+func (o *orphan) serviceDone() (bool, error) {
+	tasks, err := o.r.Client.ListTasks(docker.ListTasksOptions{
+		Filters: map[string][]string{"service": {o.id}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range tasks {
+		switch t.Status.State {
+		case swarm.TaskStateComplete, swarm.TaskStateFailed, swarm.TaskStateRejected,
+			swarm.TaskStateShutdown, swarm.TaskStateOrphaned, swarm.TaskStateRemove:
+		default:
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (o *orphan) removeService() {
+	if err := o.r.Client.RemoveService(docker.RemoveServiceOptions{ID: o.id}); err != nil {
+		o.warn("Failed to remove orphaned service %s: %s", o.id, err)
+	}
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/fsouza/go-dockerclient/testing"
+	logging "github.com/op/go-logging"
+	. "gopkg.in/check.v1"
+)
+
+type SuiteReconcile struct {
+	server *testing.DockerServer
+	client *docker.Client
+}
+
+var _ = Suite(&SuiteReconcile{})
+
+func (s *SuiteReconcile) SetUpTest(c *C) {
+	var err error
+	s.server, err = testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, IsNil)
+
+	s.client, err = docker.NewClient(s.server.URL())
+	c.Assert(err, IsNil)
+
+	(&SuiteRunJob{client: s.client}).buildImage(c)
+}
+
+func (s *SuiteReconcile) TestManagedLabels(c *C) {
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+	job.Name = "foo"
+	job.SetType("job-run")
+	job.Delete = "true"
+	job.Label = []string{"team=data"}
+
+	sh := NewScheduler(logging.MustGetLogger("ofelia"))
+	sh.InstanceID = "qux"
+	ctx := NewContext(sh, job, NewExecution())
+	ctx.Execution.ScheduledTime = time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC)
+
+	container, _, err := job.buildContainer(ctx, job.Image)
+	c.Assert(err, IsNil)
+
+	container, err = s.client.InspectContainer(container.ID)
+	c.Assert(err, IsNil)
+	c.Assert(container.Config.Labels, DeepEquals, map[string]string{
+		"team":             "data",
+		LabelManaged:       "true",
+		LabelInstance:      "qux",
+		LabelJobName:       "foo",
+		LabelExecutionID:   ctx.Execution.ID,
+		LabelDelete:        "true",
+		LabelJobType:       "job-run",
+		LabelScheduledTime: "2026-10-19T01:00:00Z",
+		LabelAttempt:       "1",
+	})
+}
+
+func (s *SuiteReconcile) TestRunReattach(c *C) {
+	finished := s.createContainer(c, DefaultInstanceID, "true", false)
+	kept := s.createContainer(c, DefaultInstanceID, "false", false)
+	other := s.createContainer(c, "other", "true", false)
+	running := s.createContainer(c, DefaultInstanceID, "true", true)
+
+	r := &Reconciler{Client: s.client, Logger: logging.MustGetLogger("ofelia")}
+	c.Assert(r.Run(), IsNil)
+
+	c.Assert(s.exists(finished), Equals, false)
+	c.Assert(s.exists(kept), Equals, true)
+	c.Assert(s.exists(other), Equals, true)
+	c.Assert(s.exists(running), Equals, true)
+
+	c.Assert(s.client.StopContainer(running, 0), IsNil)
+	time.Sleep(100 * time.Millisecond)
+	c.Assert(s.exists(running), Equals, false)
+}
+
+func (s *SuiteReconcile) TestRunRemove(c *C) {
+	running := s.createContainer(c, "foo", "false", true)
+	other := s.createContainer(c, "bar", "true", true)
+
+	r := &Reconciler{Client: s.client, Logger: logging.MustGetLogger("ofelia")}
+	r.InstanceID = "foo"
+	r.Policy = OrphanRemove
+	c.Assert(r.Run(), IsNil)
+
+	c.Assert(s.exists(running), Equals, false)
+	c.Assert(s.exists(other), Equals, true)
+}
+
+func (s *SuiteReconcile) TestRunSwarmTask(c *C) {
+	task := s.createContainer(c, DefaultInstanceID, "true", true, swarmTaskLabel, "foo")
+
+	r := &Reconciler{Client: s.client, Logger: logging.MustGetLogger("ofelia")}
+	r.Policy = OrphanRemove
+	c.Assert(r.Run(), IsNil)
+	c.Assert(s.exists(task), Equals, true)
+}
+
+func (s *SuiteReconcile) TestRunListError(c *C) {
+	s.server.CustomHandler("/containers/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "foo", http.StatusInternalServerError)
+	}))
+
+	r := &Reconciler{Client: s.client, Logger: logging.MustGetLogger("ofelia")}
+	c.Assert(r.Run(), IsNil)
+}
+
+func (s *SuiteReconcile) TestRunKeep(c *C) {
+	finished := s.createContainer(c, DefaultInstanceID, "true", false)
+
+	r := &Reconciler{Client: s.client, Logger: logging.MustGetLogger("ofelia")}
+	r.Policy = OrphanKeep
+	c.Assert(r.Run(), IsNil)
+	c.Assert(s.exists(finished), Equals, true)
+
+	r.Policy = "foo"
+	c.Assert(r.Run(), NotNil)
+}
+
+func (s *SuiteReconcile) TestRunRemoveService(c *C) {
+	_, err := s.client.InitSwarm(docker.InitSwarmOptions{})
+	c.Assert(err, IsNil)
+
+	service, err := s.client.CreateService(docker.CreateServiceOptions{
+		ServiceSpec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{
+				Name: "foo",
+				Labels: map[string]string{
+					LabelManaged:  "true",
+					LabelInstance: DefaultInstanceID,
+				},
+			},
+			TaskTemplate: swarm.TaskSpec{
+				ContainerSpec: &swarm.ContainerSpec{Image: ImageFixture},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	r := &Reconciler{Client: s.client, Logger: logging.MustGetLogger("ofelia")}
+	r.Policy = OrphanRemove
+
+	// only a swarm manager lists the services
+	s.server.CustomHandler("/info", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(docker.DockerInfo{})
+	}))
+
+	c.Assert(r.Run(), IsNil)
+	_, err = s.client.InspectService(service.ID)
+	c.Assert(err, IsNil)
+
+	// no job-service-run is needed to remove the orphaned services
+	s.server.CustomHandler("/info", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(docker.DockerInfo{Swarm: swarm.Info{ControlAvailable: true}})
+	}))
+
+	c.Assert(r.Run(), IsNil)
+	_, err = s.client.InspectService(service.ID)
+	c.Assert(err, NotNil)
+}
+
+// createContainer creates a managed container, extra are pairs of additional
+// label names and values
+func (s *SuiteReconcile) createContainer(c *C, instance, delete string, start bool, extra ...string) string {
+	labels := map[string]string{
+		LabelManaged:     "true",
+		LabelInstance:    instance,
+		LabelJobName:     "foo",
+		LabelExecutionID: "bar",
+		LabelDelete:      delete,
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		labels[extra[i]] = extra[i+1]
+	}
+
+	container, err := s.client.CreateContainer(docker.CreateContainerOptions{
+		Config: &docker.Config{
+			Image:  ImageFixture,
+			Labels: labels,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	if start {
+		c.Assert(s.client.StartContainer(container.ID, nil), IsNil)
+	}
+
+	return container.ID
+}
+
+func (s *SuiteReconcile) exists(id string) bool {
+	_, err := s.client.InspectContainer(id)
+	return err == nil
+}
@@ -1,20 +1,21 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"regexp"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/gobs/args"
 )
 
-var dockercfg *docker.AuthConfigurations
-
-func init() {
-	dockercfg, _ = docker.NewAuthConfigurationsFromDockerCfg()
-}
-
 type RunJob struct {
 	BareJob `mapstructure:",squash"`
 	Client  *docker.Client `json:"-"`
@@ -27,17 +28,81 @@ type RunJob struct {
 	// changed to "true" https://github.com/mcuadros/ofelia/issues/135
 	// so lets use strings here as workaround
 	Delete string `default:"true"`
-	Pull   string `default:"true"`
-
-	Image       string
-	Network     string
+	// Deprecated: use PullPolicy, when it is not set `true` means always and
+	// `false` if-not-present
+	Pull       string `default:"true"`
+	PullPolicy string `gcfg:"pull-policy" mapstructure:"pull-policy"`
+	Platform   string
+	// RegistryAuth is a docker config file with the registry credentials,
+	// by default the one of the user running ofelia is used
+	RegistryAuth string `gcfg:"registry-auth" mapstructure:"registry-auth"`
+
+	Image string
+	// BuildContext is a directory with a Dockerfile, the image is built from
+	// it before the run, and tagged with the hash of the context, Image is
+	// used as repository of the built image
+	BuildContext string `gcfg:"build-context" mapstructure:"build-context"`
+	Dockerfile   string
+	BuildArg     []string `gcfg:"build-arg" mapstructure:"build-arg"`
+
+	Network     []string
+	NetworkMode string `gcfg:"network-mode" mapstructure:"network-mode"`
+	Publish     []string
 	Hostname    string
 	Container   string
-	Volume      []string
-	VolumesFrom []string `gcfg:"volumes-from" mapstructure:"volumes-from,"`
-	Environment []string
-
-	containerID string
+	// ReuseMode is what to do when Container is already running, one of
+	// ReuseFailIfRunning, ReuseStartIfStopped and ReuseWaitIfRunning
+	ReuseMode string `gcfg:"reuse-mode" mapstructure:"reuse-mode"`
+	// ContainerName is the name of the created container, it is a template
+	// with the fields of containerNameData, eg.: `{{.JobName}}-{{.ExecutionID}}`
+	ContainerName string `gcfg:"container-name" mapstructure:"container-name"`
+	// NameConflict is what to do when a container with the same name exists,
+	// one of NameConflictFail, NameConflictRemove and NameConflictReuse
+	NameConflict string `gcfg:"name-conflict" mapstructure:"name-conflict"`
+	Volume       []string
+	VolumesFrom  []string `gcfg:"volumes-from" mapstructure:"volumes-from,"`
+	Environment  []string
+	// EnvFile are dotenv files and EnvFromHost names of variables of the host,
+	// merged with Environment, see buildEnvironment
+	EnvFile     []string `gcfg:"env-file" mapstructure:"env-file"`
+	EnvFromHost []string `gcfg:"env-from-host" mapstructure:"env-from-host"`
+
+	Entrypoint  string
+	WorkingDir  string `gcfg:"working-dir" mapstructure:"working-dir"`
+	Label       []string
+	Memory      string
+	MemorySwap  string   `gcfg:"memory-swap" mapstructure:"memory-swap"`
+	CPUs        string   `gcfg:"cpus" mapstructure:"cpus"`
+	CPUShares   int64    `gcfg:"cpu-shares" mapstructure:"cpu-shares"`
+	PidsLimit   int64    `gcfg:"pids-limit" mapstructure:"pids-limit"`
+	CapAdd      []string `gcfg:"cap-add" mapstructure:"cap-add"`
+	CapDrop     []string `gcfg:"cap-drop" mapstructure:"cap-drop"`
+	Privileged  bool
+	ReadOnly    bool `gcfg:"read-only" mapstructure:"read-only"`
+	Tmpfs       []string
+	Device      []string
+	SecurityOpt []string `gcfg:"security-opt" mapstructure:"security-opt"`
+	Init        bool
+	Runtime     string
+	Ulimit      []string
+	AddHost     []string `gcfg:"add-host" mapstructure:"add-host"`
+	DNS         []string `gcfg:"dns" mapstructure:"dns"`
+	LogDriver   string   `gcfg:"log-driver" mapstructure:"log-driver"`
+	LogOpt      []string `gcfg:"log-opt" mapstructure:"log-opt"`
+
+	// Upload are host files or directories copied into the container before
+	// it starts, `/host/path:/container/path`
+	Upload []string
+	// UploadContent are files created in the container before it starts,
+	// `/container/path=content`
+	UploadContent []string `gcfg:"upload-content" mapstructure:"upload-content"`
+	// Artifacts are paths copied out of the container once it exits, they
+	// are stored with the execution
+	Artifacts []string
+
+	// StreamLogs writes the output of the container to the ofelia log, line
+	// by line, while the job is running
+	StreamLogs bool `gcfg:"stream-logs" mapstructure:"stream-logs"`
 }
 
 func NewRunJob(c *docker.Client) *RunJob {
@@ -46,89 +111,80 @@ func NewRunJob(c *docker.Client) *RunJob {
 
 func (j *RunJob) Run(ctx *Context) error {
 	var container *docker.Container
+	var created bool
 	var err error
-	pull, _ := strconv.ParseBool(j.Pull)
-
-	if j.Image != "" && j.Container == "" {
-		if err = func() error {
-			var pullError error
-
-			// if Pull option "true"
-			// try pulling image first
-			if pull {
-				if pullError = j.pullImage(); pullError == nil {
-					ctx.Log("Pulled image " + j.Image)
-					return nil
-				}
-			}
-
-			// if Pull option "false"
-			// try to find image locally first
-			searchErr := j.searchLocalImage()
-			if searchErr == nil {
-				ctx.Log("Found locally image " + j.Image)
-				return nil
-			}
-
-			// if couldn't find image locally, still try to pull
-			if !pull && searchErr == ErrLocalImageNotFound {
-				if pullError = j.pullImage(); pullError == nil {
-					ctx.Log("Pulled image " + j.Image)
-					return nil
-				}
-			}
-
-			if pullError != nil {
-				return pullError
-			}
-
-			if searchErr != nil {
-				return searchErr
-			}
 
-			return nil
-		}(); err != nil {
+	if (j.Image != "" || j.BuildContext != "") && j.Container == "" {
+		var image string
+		if image, err = j.ensureImage(ctx); err != nil {
 			return err
 		}
 
-		container, err = j.buildContainer()
+		container, created, err = j.buildContainer(ctx, image)
 		if err != nil {
 			return err
 		}
 	} else {
-		container, err = j.Client.InspectContainer(j.Container)
+		container, err = j.reuseContainer(ctx)
 		if err != nil {
 			return err
 		}
 	}
 
-	if container != nil {
-		j.containerID = container.ID
-	}
+	id := container.ID
 
-	// cleanup container if it is a created one
-	if j.Container == "" {
+	// cleanup container if it is a created one, not an existing one reused
+	if created {
 		defer func() {
-			if delErr := j.deleteContainer(); delErr != nil {
+			if delErr := j.deleteContainer(id); delErr != nil {
 				ctx.Warn("failed to delete container: " + delErr.Error())
 			}
 		}()
 	}
 
+	if err := j.uploadFiles(id); err != nil {
+		return err
+	}
+
+	stdout, stderr, flush := streamWriters(ctx, j.StreamLogs)
+	defer flush()
+
+	// attaching before starting the container, no output is lost
+	attach, attachErr := j.attachContainer(id, stdout, stderr)
+	if attachErr != nil {
+		ctx.Warn("failed to attach to container, logs will be fetched at exit: " + attachErr.Error())
+	}
+
 	startTime := time.Now()
-	if err := j.startContainer(); err != nil {
+	if err := j.startContainer(id); err != nil {
+		if attach != nil {
+			attach.Close()
+		}
+
+		// started by someone else since it was inspected
+		var running *docker.ContainerAlreadyRunning
+		if j.Container != "" && errors.As(err, &running) {
+			return j.runningError()
+		}
+
 		return err
 	}
 
-	err = j.watchContainer()
+	err = j.watchContainer(ctx, id)
 	if err == ErrUnexpected {
+		if attach != nil {
+			attach.Close()
+		}
+
 		return err
 	}
 
-	if logsErr := j.Client.Logs(docker.LogsOptions{
-		Container:    container.ID,
-		OutputStream: ctx.Execution.OutputStream,
-		ErrorStream:  ctx.Execution.ErrorStream,
+	if attach != nil {
+		j.waitAttach(ctx, attach)
+	} else if logsErr := j.Client.Logs(docker.LogsOptions{
+		Container:    id,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
 		Stdout:       true,
 		Stderr:       true,
 		Since:        startTime.Unix(),
@@ -137,83 +193,380 @@ func (j *RunJob) Run(ctx *Context) error {
 		ctx.Warn("failed to fetch container logs: " + logsErr.Error())
 	}
 
+	j.downloadArtifacts(ctx, id)
 	return err
 }
 
-func (j *RunJob) searchLocalImage() error {
-	imgs, err := j.Client.ListImages(buildFindLocalImageOptions(j.Image))
+// uploadFiles copies the uploads into the container
+func (j *RunJob) uploadFiles(id string) error {
+	if len(j.Upload) == 0 && len(j.UploadContent) == 0 {
+		return nil
+	}
+
+	archive, err := buildUploadArchive(j.Upload, j.UploadContent)
 	if err != nil {
 		return err
 	}
 
-	if len(imgs) != 1 {
-		return ErrLocalImageNotFound
+	if err := j.Client.UploadToContainer(id, docker.UploadToContainerOptions{
+		InputStream: archive,
+		Path:        "/",
+	}); err != nil {
+		return fmt.Errorf("error uploading files to container: %w", err)
 	}
 
 	return nil
 }
 
-func (j *RunJob) pullImage() error {
-	o, a := buildPullOptions(j.Image)
-	if err := j.Client.PullImage(o, a); err != nil {
-		return fmt.Errorf("error pulling image %q: %s", j.Image, err)
-	}
+// downloadArtifacts copies the artifacts out of the container, a missing
+// artifact doesn't fail the execution
+func (j *RunJob) downloadArtifacts(ctx *Context, id string) {
+	for _, p := range j.Artifacts {
+		var buf bytes.Buffer
+		if err := j.Client.DownloadFromContainer(id, docker.DownloadFromContainerOptions{
+			Path:         p,
+			OutputStream: &buf,
+		}); err != nil {
+			ctx.Warn(fmt.Sprintf("failed to download artifact %q: %s", p, err))
+			continue
+		}
 
-	return nil
+		ctx.Execution.Artifacts = append(ctx.Execution.Artifacts, Artifact{Path: p, Archive: buf.Bytes()})
+	}
 }
 
-func (j *RunJob) buildContainer() (*docker.Container, error) {
-	c, err := j.Client.CreateContainer(docker.CreateContainerOptions{
-		Config: &docker.Config{
-			Image:        j.Image,
-			AttachStdin:  false,
-			AttachStdout: true,
-			AttachStderr: true,
-			Tty:          j.TTY,
-			Cmd:          args.GetArgs(j.Command),
-			User:         j.User,
-			Env:          j.Environment,
-			Hostname:     j.Hostname,
-		},
-		NetworkingConfig: &docker.NetworkingConfig{},
-		HostConfig: &docker.HostConfig{
-			Binds:       j.Volume,
-			VolumesFrom: j.VolumesFrom,
-		},
+// attachContainer attaches to the output of the container, it returns once
+// the connection is established, the output is copied until the container
+// exits.
+func (j *RunJob) attachContainer(id string, stdout, stderr io.Writer) (docker.CloseWaiter, error) {
+	success := make(chan struct{})
+	cw, err := j.Client.AttachToContainerNonBlocking(docker.AttachToContainerOptions{
+		Container:    id,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		Stdout:       true,
+		Stderr:       true,
+		Stream:       true,
+		RawTerminal:  j.TTY,
+		Success:      success,
 	})
+	if err != nil {
+		return nil, err
+	}
 
+	<-success
+	success <- struct{}{}
+	return cw, nil
+}
+
+// waitAttach waits for the remaining output of an exited container to be
+// copied, the connection is closed if it takes longer than attachTimeout
+func (j *RunJob) waitAttach(ctx *Context, attach docker.CloseWaiter) {
+	done := make(chan error, 1)
+	go func() { done <- attach.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			ctx.Warn("failed to stream container logs: " + err.Error())
+		}
+	case <-time.After(attachTimeout):
+		ctx.Warn("timeout waiting for container logs")
+		attach.Close()
+	}
+}
+
+// buildContainer creates the container of the execution, it returns false if
+// an existing container is reused instead, see NameConflictReuse
+func (j *RunJob) buildContainer(ctx *Context, image string) (*docker.Container, bool, error) {
+	opts, err := j.buildContainerOptions(image)
 	if err != nil {
-		return c, fmt.Errorf("error creating exec: %s", err)
-	}
-
-	if j.Network != "" {
-		networkOpts := docker.NetworkFilterOpts{}
-		networkOpts["name"] = map[string]bool{}
-		networkOpts["name"][j.Network] = true
-		if networks, err := j.Client.FilteredListNetworks(networkOpts); err == nil {
-			for _, network := range networks {
-				if err := j.Client.ConnectNetwork(network.ID, docker.NetworkConnectionOptions{
-					Container: c.ID,
-				}); err != nil {
-					return c, fmt.Errorf("error connecting container to network: %s", err)
-				}
-			}
+		return nil, false, err
+	}
+
+	delete, _ := strconv.ParseBool(j.Delete)
+	opts.Config.Labels = managedLabels(ctx, j.GetType(), opts.Config.Labels, delete)
+	opts.Config.Env = executionEnvironment(ctx, j.GetType(), opts.Config.Env)
+
+	networks, err := parseNetworks(j.Network)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the networks are checked before creating the container, so a missing
+	// network doesn't leave a container behind
+	for _, n := range networks {
+		if _, err := lookupNetwork(j.Client, n.name); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if opts.Name, err = j.containerName(ctx); err != nil {
+		return nil, false, err
+	}
+
+	c, err := j.Client.CreateContainer(opts)
+	if err == docker.ErrContainerAlreadyExists {
+		var reused bool
+		if c, reused, err = j.resolveNameConflict(ctx, opts); reused {
+			return c, false, err
+		}
+	}
+
+	if err != nil {
+		return c, false, fmt.Errorf("error creating exec: %s", err)
+	}
+
+	// without network mode the container is created in the first network
+	if j.NetworkMode == "" && len(networks) != 0 {
+		networks = networks[1:]
+	}
+
+	for _, n := range networks {
+		if err := j.Client.ConnectNetwork(n.name, docker.NetworkConnectionOptions{
+			Container:      c.ID,
+			EndpointConfig: n.endpoint,
+		}); err != nil {
+			j.Client.RemoveContainer(docker.RemoveContainerOptions{ID: c.ID, Force: true})
+			return nil, false, fmt.Errorf("error connecting container to network %q: %s", n.name, err)
 		}
 	}
 
-	return c, nil
+	return c, true, nil
+}
+
+// invalidNameChars are the characters not allowed in a container name
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// containerNameData are the fields available in the container-name template
+type containerNameData struct {
+	JobName     string
+	ExecutionID string
+	Date        time.Time
+}
+
+// containerName renders the container name template, the characters not
+// allowed by docker are replaced by dashes
+func (j *RunJob) containerName(ctx *Context) (string, error) {
+	if j.ContainerName == "" {
+		return "", nil
+	}
+
+	t, err := template.New("container-name").Parse(j.ContainerName)
+	if err != nil {
+		return "", fmt.Errorf("invalid container name %q: %w", j.ContainerName, err)
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, containerNameData{
+		JobName:     j.Name,
+		ExecutionID: ctx.Execution.ID,
+		Date:        ctx.Execution.Date,
+	}); err != nil {
+		return "", fmt.Errorf("invalid container name %q: %w", j.ContainerName, err)
+	}
+
+	name := strings.Trim(invalidNameChars.ReplaceAllString(b.String(), "-"), "-_.")
+	if name == "" {
+		return "", fmt.Errorf("invalid container name %q: empty name", j.ContainerName)
+	}
+
+	return name, nil
 }
 
-func (j *RunJob) startContainer() error {
-	return j.Client.StartContainer(j.containerID, &docker.HostConfig{})
+// resolveNameConflict deals with an existing container with the name of the
+// new one, following the name-conflict option. It returns true if the
+// existing container is reused.
+func (j *RunJob) resolveNameConflict(ctx *Context, opts docker.CreateContainerOptions) (*docker.Container, bool, error) {
+	policy := j.NameConflict
+	if policy == "" {
+		policy = NameConflictFail
+	}
+
+	switch policy {
+	case NameConflictFail, NameConflictRemove, NameConflictReuse:
+	default:
+		return nil, false, fmt.Errorf("unknown name conflict policy %q", policy)
+	}
+
+	existing, err := j.Client.InspectContainer(opts.Name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if policy == NameConflictFail || existing.State.Running {
+		return nil, false, fmt.Errorf("a container named %q already exists", opts.Name)
+	}
+
+	if policy == NameConflictReuse {
+		ctx.Log("Reusing existing container " + opts.Name)
+		return existing, true, nil
+	}
+
+	ctx.Log("Removing existing container " + opts.Name)
+	if err := j.Client.RemoveContainer(docker.RemoveContainerOptions{ID: existing.ID}); err != nil {
+		return nil, false, err
+	}
+
+	c, err := j.Client.CreateContainer(opts)
+	return c, false, err
 }
 
-func (j *RunJob) stopContainer(timeout uint) error {
-	return j.Client.StopContainer(j.containerID, timeout)
+// ensureImage builds the image or pulls it, following the pull policy. It
+// returns the name of the image to run, the built one when BuildContext is set.
+func (j *RunJob) ensureImage(ctx *Context) (string, error) {
+	if j.BuildContext != "" {
+		image, err := ensureBuiltImage(ctx, j.Client, &imageBuild{
+			Context:    j.BuildContext,
+			Dockerfile: j.Dockerfile,
+			Args:       j.BuildArg,
+			Platform:   j.Platform,
+		}, j.Image)
+		if err != nil {
+			return "", err
+		}
+
+		return image, nil
+	}
+
+	policy, err := parsePullPolicy(j.PullPolicy, j.Pull)
+	if err != nil {
+		return "", err
+	}
+
+	return j.Image, ensureImage(ctx, j.Client, j.Image, j.Platform, j.RegistryAuth, policy)
 }
 
-func (j *RunJob) getContainer() (*docker.Container, error) {
-	container, err := j.Client.InspectContainer(j.containerID)
+func (j *RunJob) buildContainerOptions(image string) (docker.CreateContainerOptions, error) {
+	var opts docker.CreateContainerOptions
+
+	labels, err := parseKeyValues(j.Label)
+	if err != nil {
+		return opts, err
+	}
+
+	logOpts, err := parseKeyValues(j.LogOpt)
+	if err != nil {
+		return opts, err
+	}
+
+	env, err := buildEnvironment(j.EnvFile, j.EnvFromHost, j.Environment)
+	if err != nil {
+		return opts, err
+	}
+
+	memory, err := parseMemory(j.Memory)
+	if err != nil {
+		return opts, err
+	}
+
+	memorySwap, err := parseMemorySwap(j.MemorySwap)
+	if err != nil {
+		return opts, err
+	}
+
+	cpus, err := parseCPUs(j.CPUs)
+	if err != nil {
+		return opts, err
+	}
+
+	devices, err := parseDevices(j.Device)
+	if err != nil {
+		return opts, err
+	}
+
+	ulimits, err := parseUlimits(j.Ulimit)
+	if err != nil {
+		return opts, err
+	}
+
+	networks, err := parseNetworks(j.Network)
+	if err != nil {
+		return opts, err
+	}
+
+	if isolatedNetworkMode(j.NetworkMode) && len(networks) != 0 {
+		return opts, fmt.Errorf("network mode %q can't be used with networks", j.NetworkMode)
+	}
+
+	exposedPorts, portBindings, err := parsePorts(j.Publish)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Config = &docker.Config{
+		Image:        image,
+		AttachStdin:  false,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          j.TTY,
+		Cmd:          args.GetArgs(j.Command),
+		User:         j.User,
+		Env:          env,
+		Hostname:     j.Hostname,
+		WorkingDir:   j.WorkingDir,
+		Labels:       labels,
+		ExposedPorts: exposedPorts,
+	}
+
+	if j.Entrypoint != "" {
+		opts.Config.Entrypoint = args.GetArgs(j.Entrypoint)
+	}
+
+	opts.Platform = j.Platform
+	opts.NetworkingConfig = &docker.NetworkingConfig{}
+	opts.HostConfig = &docker.HostConfig{
+		NetworkMode:    j.NetworkMode,
+		PortBindings:   portBindings,
+		Binds:          j.Volume,
+		VolumesFrom:    j.VolumesFrom,
+		Memory:         memory,
+		MemorySwap:     memorySwap,
+		NanoCPUs:       cpus,
+		CPUShares:      j.CPUShares,
+		CapAdd:         j.CapAdd,
+		CapDrop:        j.CapDrop,
+		Privileged:     j.Privileged,
+		ReadonlyRootfs: j.ReadOnly,
+		Tmpfs:          parseTmpfs(j.Tmpfs),
+		Devices:        devices,
+		SecurityOpt:    j.SecurityOpt,
+		Runtime:        j.Runtime,
+		Ulimits:        ulimits,
+		ExtraHosts:     j.AddHost,
+		DNS:            j.DNS,
+		Init:           j.Init,
+	}
+
+	if j.PidsLimit != 0 {
+		pidsLimit := j.PidsLimit
+		opts.HostConfig.PidsLimit = &pidsLimit
+	}
+
+	if j.LogDriver != "" || logOpts != nil {
+		opts.HostConfig.LogConfig = docker.LogConfig{Type: j.LogDriver, Config: logOpts}
+	}
+
+	if j.NetworkMode == "" && len(networks) != 0 {
+		opts.HostConfig.NetworkMode = networks[0].name
+		opts.NetworkingConfig.EndpointsConfig = map[string]*docker.EndpointConfig{
+			networks[0].name: networks[0].endpoint,
+		}
+	}
+
+	return opts, nil
+}
+
+func (j *RunJob) startContainer(id string) error {
+	return j.Client.StartContainer(id, &docker.HostConfig{})
+}
+
+func (j *RunJob) stopContainer(id string, timeout uint) error {
+	return j.Client.StopContainer(id, timeout)
+}
+
+func (j *RunJob) getContainer(id string) (*docker.Container, error) {
+	container, err := j.Client.InspectContainer(id)
 	if err != nil {
 		return nil, err
 	}
@@ -223,46 +576,149 @@ func (j *RunJob) getContainer() (*docker.Container, error) {
 const (
 	watchDuration      = time.Millisecond * 100
 	maxProcessDuration = time.Hour * 24
+	// seconds given to a container to stop when its execution is canceled
+	stopTimeout = 10
+	// time given to the attached streams to flush once the container exits
+	attachTimeout = time.Second * 5
 )
 
-func (j *RunJob) watchContainer() error {
-	var s docker.State
-	var r time.Duration
-	for {
-		time.Sleep(watchDuration)
-		r += watchDuration
+const (
+	// NameConflictFail fails the execution if the container name is in use
+	NameConflictFail = "fail"
+	// NameConflictRemove removes the existing container, if it is stopped
+	NameConflictRemove = "remove"
+	// NameConflictReuse starts the existing container, if it is stopped
+	NameConflictReuse = "reuse"
+)
 
-		if r > maxProcessDuration {
-			return ErrMaxTimeRunning
+const (
+	// ReuseFailIfRunning fails the execution if the container is running
+	ReuseFailIfRunning = "fail-if-running"
+	// ReuseStartIfStopped starts the container if it is stopped, and skips
+	// the execution if it is running
+	ReuseStartIfStopped = "start-if-stopped"
+	// ReuseWaitIfRunning waits for the running container to exit, and then
+	// starts it again
+	ReuseWaitIfRunning = "wait-if-running"
+)
+
+// reuseContainer inspects the existing container of the job, when it is
+// already running the reuse mode is followed
+func (j *RunJob) reuseContainer(ctx *Context) (*docker.Container, error) {
+	switch j.ReuseMode {
+	case "", ReuseFailIfRunning, ReuseStartIfStopped, ReuseWaitIfRunning:
+	default:
+		return nil, fmt.Errorf("unknown reuse mode %q", j.ReuseMode)
+	}
+
+	container, err := j.Client.InspectContainer(j.Container)
+	if err != nil {
+		return nil, err
+	}
+
+	if !container.State.Running {
+		return container, nil
+	}
+
+	if j.ReuseMode != ReuseWaitIfRunning {
+		return nil, j.runningError()
+	}
+
+	ctx.Log(fmt.Sprintf("Container %s is running, waiting for it to exit", j.Container))
+
+	waitCtx, cancel := context.WithTimeout(ctx.Execution.Context(), maxProcessDuration)
+	defer cancel()
+
+	if _, err := j.Client.WaitContainerWithContext(container.ID, waitCtx); err != nil {
+		if canceled := ctx.Execution.Canceled(); canceled != nil {
+			return nil, canceled
 		}
 
-		c, err := j.Client.InspectContainer(j.containerID)
-		if err != nil {
-			return err
+		return nil, fmt.Errorf("error waiting for container %q: %w", j.Container, err)
+	}
+
+	return container, nil
+}
+
+func (j *RunJob) runningError() error {
+	if j.ReuseMode == ReuseStartIfStopped {
+		return fmt.Errorf("%w: container %q is already running", ErrSkippedExecution, j.Container)
+	}
+
+	return fmt.Errorf("container %q is already running", j.Container)
+}
+
+// watchContainer waits for the container to finish using the wait API, if
+// the API call fails it falls back to polling the state of the container.
+func (j *RunJob) watchContainer(ctx *Context, id string) error {
+	deadline := time.Now().Add(maxProcessDuration)
+	waitCtx, cancel := context.WithDeadline(ctx.Execution.Context(), deadline)
+	defer cancel()
+
+	exitCode, err := j.Client.WaitContainerWithContext(id, waitCtx)
+	if err != nil {
+		switch {
+		case ctx.Execution.Context().Err() != nil:
+			return j.cancelContainer(ctx, id)
+		case waitCtx.Err() != nil:
+			return ErrMaxTimeRunning
 		}
 
-		if !c.State.Running {
-			s = c.State
-			break
+		ctx.Warn("failed to wait for container, polling its state instead: " + err.Error())
+		if exitCode, err = j.pollContainer(ctx, id, deadline); err != nil {
+			return err
 		}
 	}
 
-	switch s.ExitCode {
+	switch exitCode {
 	case 0:
 		return nil
 	case -1:
 		return ErrUnexpected
 	default:
-		return fmt.Errorf("error non-zero exit code: %d", s.ExitCode)
+		return fmt.Errorf("error non-zero exit code: %d", exitCode)
+	}
+}
+
+func (j *RunJob) pollContainer(ctx *Context, id string, deadline time.Time) (int, error) {
+	for {
+		select {
+		case <-ctx.Execution.Context().Done():
+			return 0, j.cancelContainer(ctx, id)
+		case <-time.After(watchDuration):
+		}
+
+		if time.Now().After(deadline) {
+			return 0, ErrMaxTimeRunning
+		}
+
+		c, err := j.Client.InspectContainer(id)
+		if err != nil {
+			return 0, err
+		}
+
+		if !c.State.Running {
+			return c.State.ExitCode, nil
+		}
+	}
+}
+
+// cancelContainer stops the container of a canceled execution, returning the
+// cause of the cancellation
+func (j *RunJob) cancelContainer(ctx *Context, id string) error {
+	if err := j.stopContainer(id, stopTimeout); err != nil {
+		ctx.Warn("failed to stop container: " + err.Error())
 	}
+
+	return ctx.Execution.Canceled()
 }
 
-func (j *RunJob) deleteContainer() error {
+func (j *RunJob) deleteContainer(id string) error {
 	if delete, _ := strconv.ParseBool(j.Delete); !delete {
 		return nil
 	}
 
 	return j.Client.RemoveContainer(docker.RemoveContainerOptions{
-		ID: j.containerID,
+		ID: id,
 	})
 }
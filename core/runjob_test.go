@@ -3,6 +3,10 @@ package core
 import (
 	"archive/tar"
 	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
@@ -39,9 +43,10 @@ func (s *SuiteRunJob) TestRun(c *C) {
 	job.User = "foo"
 	job.TTY = true
 	job.Delete = "true"
-	job.Network = "foo"
+	job.Network = []string{"foo"}
 	job.Hostname = "test-host"
 	job.Name = "test"
+	job.SetType("job-run")
 	job.Environment = []string{"test_Key1=value1", "test_Key2=value2"}
 	job.Volume = []string{"/test/tmp:/test/tmp:ro", "/test/tmp:/test/tmp:rw"}
 
@@ -60,25 +65,31 @@ func (s *SuiteRunJob) TestRun(c *C) {
 	}()
 
 	time.Sleep(200 * time.Millisecond)
-	container, err := job.getContainer()
+	id := s.runningContainer(c)
+	container, err := job.getContainer(id)
 	c.Assert(err, IsNil)
 	c.Assert(container.Config.Cmd, DeepEquals, []string{"echo", "-a", "foo bar"})
 	c.Assert(container.Config.User, Equals, job.User)
 	c.Assert(container.Config.Image, Equals, job.Image)
 	c.Assert(container.State.Running, Equals, true)
-	c.Assert(container.Config.Env, DeepEquals, job.Environment)
+	c.Assert(container.Config.Env, DeepEquals, append([]string{
+		"OFELIA_JOB_TYPE=job-run",
+		"OFELIA_JOB_NAME=test",
+		"OFELIA_EXECUTION_ID=" + ctx.Execution.ID,
+		"OFELIA_ATTEMPT=1",
+	}, job.Environment...))
 
 	// this doesn't seem to be working with DockerTestServer
 	// c.Assert(container.Config.Hostname, Equals, job.Hostname)
 	// c.Assert(container.HostConfig.Binds, DeepEquals, job.Volume)
 
 	// stop container, we don't need it anymore
-	err = job.stopContainer(0)
+	err = job.stopContainer(id, 0)
 	c.Assert(err, IsNil)
 
 	// wait and double check if container was deleted on "stop"
 	time.Sleep(watchDuration * 2)
-	container, _ = job.getContainer()
+	container, _ = job.getContainer(id)
 	c.Assert(container, IsNil)
 
 	containers, err := s.client.ListContainers(docker.ListContainersOptions{All: true})
@@ -86,28 +97,377 @@ func (s *SuiteRunJob) TestRun(c *C) {
 	c.Assert(containers, HasLen, 0)
 }
 
+func (s *SuiteRunJob) TestRunAttached(c *C) {
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+	job.Delete = "true"
+	job.StreamLogs = true
+	job.Name = "test"
+
+	ctx := newContext(job)
+
+	done := make(chan error)
+	go func() { done <- job.Run(ctx) }()
+
+	time.Sleep(200 * time.Millisecond)
+	c.Assert(job.stopContainer(s.runningContainer(c), 0), IsNil)
+	c.Assert(<-done, IsNil)
+
+	// the test server writes a fixed output to attached clients
+	c.Assert(ctx.Execution.OutputStream.String(), Matches, "(?s).*Something happened.*")
+}
+
+func (s *SuiteRunJob) TestWatchContainerCanceled(c *C) {
+	job, ctx, id := s.startContainer(c)
+
+	cause := errors.New("foo")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ctx.Execution.Cancel(cause)
+	}()
+
+	c.Assert(job.watchContainer(ctx, id), Equals, cause)
+
+	container, err := job.getContainer(id)
+	c.Assert(err, IsNil)
+	c.Assert(container.State.Running, Equals, false)
+}
+
+func (s *SuiteRunJob) TestWatchContainerPollingFallback(c *C) {
+	s.server.PrepareFailure("wait", "/containers/.*/wait")
+	job, ctx, id := s.startContainer(c)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		job.stopContainer(id, 0)
+	}()
+
+	c.Assert(job.watchContainer(ctx, id), IsNil)
+}
+
+func (s *SuiteRunJob) startContainer(c *C) (*RunJob, *Context, string) {
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+
+	ctx := newContext(job)
+
+	container, _, err := job.buildContainer(ctx, job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(job.startContainer(container.ID), IsNil)
+
+	return job, ctx, container.ID
+}
+
+// runningContainer returns the ID of the only running container
+func (s *SuiteRunJob) runningContainer(c *C) string {
+	containers, err := s.client.ListContainers(docker.ListContainersOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(containers, HasLen, 1)
+
+	return containers[0].ID
+}
+
+func (s *SuiteRunJob) TestEnsureImageBuildContext(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine\n"), 0o644), IsNil)
+
+	job := &RunJob{Client: s.client}
+	job.Name = "report"
+	job.BuildContext = dir
+
+	ctx := newContext(job)
+	image, err := job.ensureImage(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(strings.HasPrefix(image, "ofelia/report:"), Equals, true)
+
+	opts, err := job.buildContainerOptions(image)
+	c.Assert(err, IsNil)
+	c.Assert(opts.Config.Image, Equals, image)
+}
+
+func (s *SuiteRunJob) TestRunExistingContainerRunning(c *C) {
+	_, _, id := s.startContainer(c)
+
+	job := &RunJob{Client: s.client}
+	job.Name = "toolbox"
+	job.Container = id
+	ctx := newContext(job)
+
+	err := job.Run(ctx)
+	c.Assert(err, ErrorMatches, ".*is already running")
+	c.Assert(errors.Is(err, ErrSkippedExecution), Equals, false)
+
+	job.ReuseMode = ReuseStartIfStopped
+	err = job.Run(ctx)
+	c.Assert(errors.Is(err, ErrSkippedExecution), Equals, true)
+
+	job.ReuseMode = "foo"
+	c.Assert(job.Run(ctx), ErrorMatches, `unknown reuse mode "foo"`)
+}
+
+func (s *SuiteRunJob) TestRunExistingContainerWaitIfRunning(c *C) {
+	running, _, id := s.startContainer(c)
+
+	job := &RunJob{Client: s.client}
+	job.Name = "toolbox"
+	job.Container = id
+	job.ReuseMode = ReuseWaitIfRunning
+	ctx := newContext(job)
+
+	done := make(chan error, 1)
+	go func() { done <- job.Run(ctx) }()
+
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case err := <-done:
+		c.Fatalf("job finished while the container was running: %v", err)
+	default:
+	}
+
+	// the container exits, the job starts it again
+	c.Assert(running.stopContainer(id, 0), IsNil)
+	time.Sleep(200 * time.Millisecond)
+
+	container, err := running.getContainer(id)
+	c.Assert(err, IsNil)
+	c.Assert(container.State.Running, Equals, true)
+
+	c.Assert(running.stopContainer(id, 0), IsNil)
+	c.Assert(<-done, IsNil)
+}
+
+func (s *SuiteRunJob) TestBuildContainerOptions(c *C) {
+	job := &RunJob{}
+	job.Image = ImageFixture
+	job.Command = "report --all"
+	job.Entrypoint = "/bin/sh -c"
+	job.WorkingDir = "/srv"
+	job.Label = []string{"team=data"}
+	job.Memory = "256m"
+	job.CPUs = "0.5"
+	job.PidsLimit = 100
+	job.CapAdd = []string{"NET_ADMIN"}
+	job.CapDrop = []string{"ALL"}
+	job.ReadOnly = true
+	job.Tmpfs = []string{"/tmp:size=64m"}
+	job.Init = true
+	job.Runtime = "runsc"
+	job.Ulimit = []string{"nofile=1024:2048"}
+	job.AddHost = []string{"db:10.0.0.2"}
+	job.DNS = []string{"1.1.1.1"}
+	job.LogDriver = "json-file"
+	job.LogOpt = []string{"max-size=10m"}
+
+	opts, err := job.buildContainerOptions(job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(opts.Config.Cmd, DeepEquals, []string{"report", "--all"})
+	c.Assert(opts.Config.Entrypoint, DeepEquals, []string{"/bin/sh", "-c"})
+	c.Assert(opts.Config.WorkingDir, Equals, "/srv")
+	c.Assert(opts.Config.Labels, DeepEquals, map[string]string{"team": "data"})
+	c.Assert(opts.HostConfig.Memory, Equals, int64(256*1024*1024))
+	c.Assert(opts.HostConfig.NanoCPUs, Equals, int64(500000000))
+	c.Assert(*opts.HostConfig.PidsLimit, Equals, int64(100))
+	c.Assert(opts.HostConfig.CapAdd, DeepEquals, []string{"NET_ADMIN"})
+	c.Assert(opts.HostConfig.CapDrop, DeepEquals, []string{"ALL"})
+	c.Assert(opts.HostConfig.ReadonlyRootfs, Equals, true)
+	c.Assert(opts.HostConfig.Tmpfs, DeepEquals, map[string]string{"/tmp": "size=64m"})
+	c.Assert(opts.HostConfig.Init, Equals, true)
+	c.Assert(opts.HostConfig.Runtime, Equals, "runsc")
+	c.Assert(opts.HostConfig.Ulimits, DeepEquals, []docker.ULimit{{Name: "nofile", Soft: 1024, Hard: 2048}})
+	c.Assert(opts.HostConfig.ExtraHosts, DeepEquals, []string{"db:10.0.0.2"})
+	c.Assert(opts.HostConfig.DNS, DeepEquals, []string{"1.1.1.1"})
+	c.Assert(opts.HostConfig.LogConfig, DeepEquals, docker.LogConfig{
+		Type:   "json-file",
+		Config: map[string]string{"max-size": "10m"},
+	})
+}
+
+func (s *SuiteRunJob) TestBuildContainerOptionsInvalid(c *C) {
+	job := &RunJob{}
+	job.Memory = "foo"
+
+	_, err := job.buildContainerOptions(job.Image)
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteRunJob) TestBuildContainerOptionsNetworks(c *C) {
+	job := &RunJob{}
+	job.Network = []string{"name=foo,alias=etl", "bar"}
+	job.Publish = []string{"8080:80"}
+
+	opts, err := job.buildContainerOptions(job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(opts.HostConfig.NetworkMode, Equals, "foo")
+	c.Assert(opts.NetworkingConfig.EndpointsConfig, DeepEquals, map[string]*docker.EndpointConfig{
+		"foo": {Aliases: []string{"etl"}},
+	})
+	c.Assert(opts.HostConfig.PortBindings, DeepEquals, map[docker.Port][]docker.PortBinding{
+		"80/tcp": {{HostPort: "8080"}},
+	})
+
+	job.NetworkMode = "host"
+	_, err = job.buildContainerOptions(job.Image)
+	c.Assert(err, NotNil)
+
+	job.Network = nil
+	opts, err = job.buildContainerOptions(job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(opts.HostConfig.NetworkMode, Equals, "host")
+	c.Assert(opts.NetworkingConfig.EndpointsConfig, IsNil)
+}
+
+func (s *SuiteRunJob) TestBuildContainerNetworks(c *C) {
+	_, err := s.client.CreateNetwork(docker.CreateNetworkOptions{Name: "bar", Driver: "bridge"})
+	c.Assert(err, IsNil)
+
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+	job.Network = []string{"foo", "name=bar,alias=etl"}
+
+	container, _, err := job.buildContainer(newContext(job), job.Image)
+	c.Assert(err, IsNil)
+
+	network, err := s.client.NetworkInfo("bar")
+	c.Assert(err, IsNil)
+	c.Assert(network.Containers, HasLen, 1)
+	_, ok := network.Containers[container.ID]
+	c.Assert(ok, Equals, true)
+}
+
+func (s *SuiteRunJob) TestBuildContainerMissingNetwork(c *C) {
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+	job.Network = []string{"fo"}
+
+	_, _, err := job.buildContainer(newContext(job), job.Image)
+	c.Assert(err, ErrorMatches, `network "fo" not found`)
+
+	containers, err := s.client.ListContainers(docker.ListContainersOptions{All: true})
+	c.Assert(err, IsNil)
+	c.Assert(containers, HasLen, 0)
+}
+
+func (s *SuiteRunJob) TestContainerName(c *C) {
+	job := &RunJob{}
+	job.Name = "backup db"
+	job.ContainerName = `ofelia-{{.JobName}}-{{.Date.Format "20060102"}}-{{.ExecutionID}}`
+
+	e := NewExecution()
+	e.Date = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+	name, err := job.containerName(&Context{Execution: e})
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "ofelia-backup-db-20261019-"+e.ID)
+
+	job.ContainerName = "{{.Foo}}"
+	_, err = job.containerName(&Context{Execution: e})
+	c.Assert(err, NotNil)
+
+	job.ContainerName = "/"
+	_, err = job.containerName(&Context{Execution: e})
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteRunJob) TestBuildContainerNameConflict(c *C) {
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+	job.ContainerName = "{{.JobName}}"
+	job.Name = "foo"
+
+	ctx := newContext(job)
+
+	existing, created, err := job.buildContainer(ctx, job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(created, Equals, true)
+
+	_, _, err = job.buildContainer(ctx, job.Image)
+	c.Assert(err, ErrorMatches, `.*a container named "foo" already exists`)
+
+	job.NameConflict = NameConflictReuse
+	container, created, err := job.buildContainer(ctx, job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(container.ID, Equals, existing.ID)
+	c.Assert(created, Equals, false)
+
+	job.NameConflict = NameConflictRemove
+	container, created, err = job.buildContainer(ctx, job.Image)
+	c.Assert(err, IsNil)
+	c.Assert(created, Equals, true)
+	c.Assert(container.ID, Not(Equals), existing.ID)
+
+	// running containers are never removed nor reused
+	c.Assert(job.startContainer(container.ID), IsNil)
+	_, _, err = job.buildContainer(ctx, job.Image)
+	c.Assert(err, ErrorMatches, `.*already exists`)
+}
+
+func (s *SuiteRunJob) TestRunNameConflictReuseKeepsContainer(c *C) {
+	existing, err := s.client.CreateContainer(docker.CreateContainerOptions{
+		Name:   "foo",
+		Config: &docker.Config{Image: ImageFixture},
+	})
+	c.Assert(err, IsNil)
+
+	job := &RunJob{Client: s.client}
+	job.Image = ImageFixture
+	job.Name = "foo"
+	job.ContainerName = "{{.JobName}}"
+	job.NameConflict = NameConflictReuse
+	job.Delete = "true"
+	job.PullPolicy = PullNever
+
+	ctx := newContext(job)
+
+	done := make(chan error, 1)
+	go func() { done <- job.Run(ctx) }()
+
+	time.Sleep(200 * time.Millisecond)
+	c.Assert(s.client.StopContainer(existing.ID, 0), IsNil)
+	c.Assert(<-done, IsNil)
+
+	// the reused container was not created by the execution, it is kept
+	_, err = s.client.InspectContainer(existing.ID)
+	c.Assert(err, IsNil)
+}
+
+func (s *SuiteRunJob) TestUploadAndArtifacts(c *C) {
+	job, ctx, id := s.startContainer(c)
+	job.UploadContent = []string{"/app/config.json={}"}
+	job.Artifacts = []string{"/app/config.json", "/missing"}
+
+	c.Assert(job.uploadFiles(id), IsNil)
+
+	job.downloadArtifacts(ctx, id)
+	c.Assert(ctx.Execution.Artifacts, HasLen, 1)
+	c.Assert(ctx.Execution.Artifacts[0].Path, Equals, "/app/config.json")
+}
+
 func (s *SuiteRunJob) TestBuildPullImageOptionsBareImage(c *C) {
-	o, _ := buildPullOptions("foo")
+	o := buildPullOptions("foo")
 	c.Assert(o.Repository, Equals, "foo")
 	c.Assert(o.Tag, Equals, "latest")
 	c.Assert(o.Registry, Equals, "")
 }
 
 func (s *SuiteRunJob) TestBuildPullImageOptionsVersion(c *C) {
-	o, _ := buildPullOptions("foo:qux")
+	o := buildPullOptions("foo:qux")
 	c.Assert(o.Repository, Equals, "foo")
 	c.Assert(o.Tag, Equals, "qux")
 	c.Assert(o.Registry, Equals, "")
 }
 
 func (s *SuiteRunJob) TestBuildPullImageOptionsRegistry(c *C) {
-	o, _ := buildPullOptions("quay.io/srcd/rest:qux")
+	o := buildPullOptions("quay.io/srcd/rest:qux")
 	c.Assert(o.Repository, Equals, "quay.io/srcd/rest")
 	c.Assert(o.Tag, Equals, "qux")
 	c.Assert(o.Registry, Equals, "quay.io")
 }
 
 func (s *SuiteRunJob) buildImage(c *C) {
+	s.buildImageNamed(c, ImageFixture)
+}
+
+func (s *SuiteRunJob) buildImageNamed(c *C, name string) {
 	inputbuf := bytes.NewBuffer(nil)
 	tr := tar.NewWriter(inputbuf)
 	tr.WriteHeader(&tar.Header{Name: "Dockerfile"})
@@ -115,7 +475,7 @@ func (s *SuiteRunJob) buildImage(c *C) {
 	tr.Close()
 
 	err := s.client.BuildImage(docker.BuildImageOptions{
-		Name:         ImageFixture,
+		Name:         name,
 		InputStream:  inputbuf,
 		OutputStream: bytes.NewBuffer(nil),
 	})
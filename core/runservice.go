@@ -1,14 +1,15 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	docker "github.com/fsouza/go-dockerclient"
+	"github.com/gobs/args"
 )
 
 // Note: The ServiceJob is loosely inspired by https://github.com/alexellis/jaas/
@@ -22,180 +23,505 @@ type RunServiceJob struct {
 	// user would set it to "false" explicitly, it still will be
 	// changed to "true" https://github.com/mcuadros/ofelia/issues/135
 	// so lets use strings here as workaround
-	Delete  string `default:"true"`
-	Image   string
-	Network string
+	Delete     string `default:"true"`
+	Image      string
+	Network    string
+	PullPolicy string `gcfg:"pull-policy" mapstructure:"pull-policy"`
+	Platform   string
+	// RegistryAuth is a docker config file with the registry credentials,
+	// by default the one of the user running ofelia is used, they are sent
+	// with the service so every node can pull the image
+	RegistryAuth string `gcfg:"registry-auth" mapstructure:"registry-auth"`
+
+	Environment []string
+	// EnvFile are dotenv files and EnvFromHost names of variables of the host,
+	// merged with Environment, see buildEnvironment
+	EnvFile     []string `gcfg:"env-file" mapstructure:"env-file"`
+	EnvFromHost []string `gcfg:"env-from-host" mapstructure:"env-from-host"`
+	Mount       []string
+	// Secret and Config are swarm secrets and configs given by name
+	Secret     []string
+	Config     []string
+	Constraint []string
+	Memory     string
+	CPUs       string `gcfg:"cpus" mapstructure:"cpus"`
+	Label      []string
+	// MaxRuntime is how long the service can run, it is removed afterwards
+	MaxRuntime string `gcfg:"max-runtime" mapstructure:"max-runtime"`
+	// Mode is the service mode, by default a single task is run, one of
+	// ServiceModeReplicatedJob and ServiceModeGlobalJob
+	Mode string
+	// TotalCompletions and MaxConcurrent are the number of tasks to complete
+	// and to run at the same time of a replicated job
+	TotalCompletions uint64 `gcfg:"total-completions" mapstructure:"total-completions"`
+	MaxConcurrent    uint64 `gcfg:"max-concurrent" mapstructure:"max-concurrent"`
 }
 
+const (
+	// ServiceModeReplicatedJob runs tasks until TotalCompletions of them
+	// complete, at most MaxConcurrent at the same time
+	ServiceModeReplicatedJob = "replicated-job"
+	// ServiceModeGlobalJob runs a task on every node matching the constraints
+	ServiceModeGlobalJob = "global-job"
+)
+
 func NewRunServiceJob(c *docker.Client) *RunServiceJob {
 	return &RunServiceJob{Client: c}
 }
 
 func (j *RunServiceJob) Run(ctx *Context) error {
-	if err := j.pullImage(); err != nil {
+	timeout, err := j.maxRuntime()
+	if err != nil {
 		return err
 	}
 
-	svc, err := j.buildService()
+	policy, err := parsePullPolicy(j.PullPolicy, "")
+	if err != nil {
+		return err
+	}
+
+	if err := ensureImage(ctx, j.Client, j.Image, j.Platform, j.RegistryAuth, policy); err != nil {
+		return err
+	}
 
+	svc, err := j.buildService(ctx)
 	if err != nil {
 		return err
 	}
 
 	ctx.Logger.Noticef("Created service %s for job %s\n", svc.ID, j.Name)
 
-	if err := j.watchContainer(ctx, svc.ID); err != nil {
+	err = j.watchContainer(ctx, svc.ID, timeout)
+	if cause := ctx.Execution.Canceled(); cause != nil || err == ErrMaxTimeRunning {
+		// a canceled or timed out service is always removed, otherwise its
+		// task keeps running
+		if err := j.removeService(ctx, svc.ID); err != nil {
+			ctx.Warn("failed to remove service: " + err.Error())
+		}
+
+		if cause != nil {
+			return cause
+		}
+
 		return err
 	}
 
-	return j.deleteService(ctx, svc.ID)
+	if delErr := j.deleteService(ctx, svc.ID); delErr != nil {
+		if err == nil {
+			return delErr
+		}
+
+		ctx.Warn("failed to delete service: " + delErr.Error())
+	}
+
+	return err
 }
 
-func (j *RunServiceJob) pullImage() error {
-	o, a := buildPullOptions(j.Image)
-	if err := j.Client.PullImage(o, a); err != nil {
-		return fmt.Errorf("error pulling image %q: %s", j.Image, err)
+// maxRuntime returns how long the service can run before being removed
+func (j *RunServiceJob) maxRuntime() (time.Duration, error) {
+	if j.MaxRuntime == "" {
+		return maxProcessDuration, nil
+	}
+
+	d, err := time.ParseDuration(j.MaxRuntime)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid max runtime %q", j.MaxRuntime)
+	}
+
+	return d, nil
+}
+
+func (j *RunServiceJob) buildService(ctx *Context) (*swarm.Service, error) {
+	opts, err := j.buildServiceOptions(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return j.Client.CreateService(opts)
 }
 
-func (j *RunServiceJob) buildService() (*swarm.Service, error) {
+func (j *RunServiceJob) buildServiceOptions(ctx *Context) (docker.CreateServiceOptions, error) {
+	var opts docker.CreateServiceOptions
+
+	// parsed twice, the managed labels are merged into each of them
+	serviceLabels, err := parseKeyValues(j.Label)
+	if err != nil {
+		return opts, err
+	}
+
+	containerLabels, _ := parseKeyValues(j.Label)
+
+	mounts, err := parseMounts(j.Mount)
+	if err != nil {
+		return opts, err
+	}
+
+	secrets, err := j.secretReferences()
+	if err != nil {
+		return opts, err
+	}
+
+	configs, err := j.configReferences()
+	if err != nil {
+		return opts, err
+	}
+
+	memory, err := parseMemory(j.Memory)
+	if err != nil {
+		return opts, err
+	}
+
+	cpus, err := parseCPUs(j.CPUs)
+	if err != nil {
+		return opts, err
+	}
 
-	//createOptions := types.ServiceCreateOptions{}
+	env, err := buildEnvironment(j.EnvFile, j.EnvFromHost, j.Environment)
+	if err != nil {
+		return opts, err
+	}
 
+	delete, _ := strconv.ParseBool(j.Delete)
+	opts.ServiceSpec.Annotations.Labels = managedLabels(ctx, j.GetType(), serviceLabels, delete)
+	opts.ServiceSpec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{
+		Image:   j.Image,
+		Labels:  managedLabels(ctx, j.GetType(), containerLabels, delete),
+		Command: args.GetArgs(j.Command),
+		User:    j.User,
+		TTY:     j.TTY,
+		Env:     executionEnvironment(ctx, j.GetType(), env),
+		Mounts:  mounts,
+		Secrets: secrets,
+		Configs: configs,
+	}
+
+	if opts.ServiceSpec.Mode, err = j.serviceMode(); err != nil {
+		return opts, err
+	}
+
+	// Make the service run once and not restart
 	max := uint64(1)
-	createSvcOpts := docker.CreateServiceOptions{}
+	opts.ServiceSpec.TaskTemplate.RestartPolicy = &swarm.RestartPolicy{
+		MaxAttempts: &max,
+		Condition:   swarm.RestartPolicyConditionNone,
+	}
 
-	createSvcOpts.ServiceSpec.TaskTemplate.ContainerSpec =
-		&swarm.ContainerSpec{
-			Image: j.Image,
+	if memory != 0 || cpus != 0 {
+		opts.ServiceSpec.TaskTemplate.Resources = &swarm.ResourceRequirements{
+			Limits: &swarm.Limit{NanoCPUs: cpus, MemoryBytes: memory},
 		}
+	}
 
-	// Make the service run once and not restart
-	createSvcOpts.ServiceSpec.TaskTemplate.RestartPolicy =
-		&swarm.RestartPolicy{
-			MaxAttempts: &max,
-			Condition:   swarm.RestartPolicyConditionNone,
+	if j.Platform != "" || len(j.Constraint) != 0 {
+		placement := &swarm.Placement{Constraints: j.Constraint}
+		if j.Platform != "" {
+			os, arch, _ := strings.Cut(j.Platform, "/")
+			placement.Platforms = []swarm.Platform{{OS: os, Architecture: arch}}
 		}
 
+		opts.ServiceSpec.TaskTemplate.Placement = placement
+	}
+
 	// For a service to interact with other services in a stack,
 	// we need to attach it to the same network
 	if j.Network != "" {
-		createSvcOpts.Networks = []swarm.NetworkAttachmentConfig{
-			swarm.NetworkAttachmentConfig{
-				Target: j.Network,
-			},
+		opts.Networks = []swarm.NetworkAttachmentConfig{{Target: j.Network}}
+	}
+
+	opts.Auth, err = lookupAuth(j.RegistryAuth, buildPullOptions(j.Image).Registry)
+	if err != nil {
+		if j.RegistryAuth != "" {
+			return opts, err
 		}
+
+		ctx.Warn(err.Error() + ", creating the service without registry credentials")
 	}
 
-	if j.Command != "" {
-		createSvcOpts.ServiceSpec.TaskTemplate.ContainerSpec.Command = strings.Split(j.Command, " ")
+	return opts, nil
+}
+
+func (j *RunServiceJob) serviceMode() (swarm.ServiceMode, error) {
+	var mode swarm.ServiceMode
+	if j.Mode != ServiceModeReplicatedJob && (j.TotalCompletions != 0 || j.MaxConcurrent != 0) {
+		return mode, fmt.Errorf("total-completions and max-concurrent require the %s mode", ServiceModeReplicatedJob)
 	}
 
-	svc, err := j.Client.CreateService(createSvcOpts)
-	if err != nil {
-		return nil, err
+	switch j.Mode {
+	case "":
+	case ServiceModeReplicatedJob:
+		mode.ReplicatedJob = &swarm.ReplicatedJob{}
+		if j.TotalCompletions != 0 {
+			mode.ReplicatedJob.TotalCompletions = &j.TotalCompletions
+		}
+
+		if j.MaxConcurrent != 0 {
+			mode.ReplicatedJob.MaxConcurrent = &j.MaxConcurrent
+		}
+	case ServiceModeGlobalJob:
+		mode.GlobalJob = &swarm.GlobalJob{}
+	default:
+		return mode, fmt.Errorf("unknown service mode %q", j.Mode)
 	}
 
-	return svc, err
+	return mode, nil
 }
 
-const (
-
-	// TODO are these const defined somewhere in the docker API?
-	swarmError   = -999
-	timeoutError = -998
-)
+// secretReferences resolves the secrets of the job by name
+func (j *RunServiceJob) secretReferences() ([]*swarm.SecretReference, error) {
+	var refs []*swarm.SecretReference
+	for _, s := range j.Secret {
+		ref, err := parseFileReference(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret: %w", err)
+		}
 
-var svcChecker = time.NewTicker(watchDuration)
+		secrets, err := j.Client.ListSecrets(docker.ListSecretsOptions{
+			Filters: map[string][]string{"name": {ref.Name}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up secret %q: %w", ref.Name, err)
+		}
 
-func (j *RunServiceJob) watchContainer(ctx *Context, svcID string) error {
-	exitCode := swarmError
+		// the name filter matches by prefix
+		var id string
+		for _, secret := range secrets {
+			if secret.Spec.Name == ref.Name {
+				id = secret.ID
+			}
+		}
 
-	ctx.Logger.Noticef("Checking for service ID %s (%s) termination\n", svcID, j.Name)
+		if id == "" {
+			return nil, fmt.Errorf("secret %q not found", ref.Name)
+		}
 
-	svc, err := j.Client.InspectService(svcID)
-	if err != nil {
-		return fmt.Errorf("Failed to inspect service %s: %s", svcID, err.Error())
+		file := ref.File
+		refs = append(refs, &swarm.SecretReference{File: &file, SecretID: id, SecretName: ref.Name})
 	}
 
-	// On every tick, check if all the services have completed, or have error out
-	var wg sync.WaitGroup
-	wg.Add(1)
+	return refs, nil
+}
 
-	go func() {
-		defer wg.Done()
-		for _ = range svcChecker.C {
+// configReferences resolves the configs of the job by name
+func (j *RunServiceJob) configReferences() ([]*swarm.ConfigReference, error) {
+	var refs []*swarm.ConfigReference
+	for _, c := range j.Config {
+		ref, err := parseFileReference(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
 
-			if svc.CreatedAt.After(time.Now().Add(maxProcessDuration)) {
-				err = ErrMaxTimeRunning
-				return
+		configs, err := j.Client.ListConfigs(docker.ListConfigsOptions{
+			Filters: map[string][]string{"name": {ref.Name}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error looking up config %q: %w", ref.Name, err)
+		}
+
+		var id string
+		for _, config := range configs {
+			if config.Spec.Name == ref.Name {
+				id = config.ID
 			}
+		}
 
-			taskExitCode, found := j.findtaskstatus(ctx, svc.ID)
+		if id == "" {
+			return nil, fmt.Errorf("config %q not found", ref.Name)
+		}
 
-			if found {
-				exitCode = taskExitCode
-				return
-			}
+		file := swarm.ConfigReferenceFileTarget(ref.File)
+		refs = append(refs, &swarm.ConfigReference{File: &file, ConfigID: id, ConfigName: ref.Name})
+	}
+
+	return refs, nil
+}
+
+// maxServiceWatchInterval is the longest interval between two checks of the
+// tasks of a service, the interval doubles from watchDuration up to it
+const maxServiceWatchInterval = 5 * time.Second
+
+// watchContainer waits for the task of the service to finish, an error is
+// returned if the task failed or was rejected, or if it is still running
+// after the given timeout
+func (j *RunServiceJob) watchContainer(ctx *Context, svcID string, timeout time.Duration) error {
+	ctx.Logger.Noticef("Checking for service ID %s (%s) termination\n", svcID, j.Name)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	interval := watchDuration
+	next := time.NewTimer(interval)
+	defer next.Stop()
+
+	var tasks []swarm.Task
+	watch := &taskWatch{}
+	for {
+		finished, done, err := j.findFinishedTasks(svcID, watch)
+		if err != nil {
+			// a transient error of the API, the task is checked again until
+			// the timeout
+			ctx.Warn("failed to list the tasks of the service, retrying: " + err.Error())
+		} else if done {
+			tasks = finished
+			break
 		}
-	}()
 
-	wg.Wait()
+		select {
+		case <-ctx.Execution.Context().Done():
+			return ctx.Execution.Canceled()
+		case <-deadline.C:
+			return ErrMaxTimeRunning
+		case <-next.C:
+		}
 
-	ctx.Logger.Noticef("Service ID %s (%s) has completed with exit code %d\n", svcID, j.Name, exitCode)
-	return err
+		if interval *= 2; interval > maxServiceWatchInterval {
+			interval = maxServiceWatchInterval
+		}
+
+		next.Reset(interval)
+	}
+
+	j.fetchLogs(ctx, svcID)
+
+	if len(tasks) == 0 {
+		ctx.Logger.Noticef("Service ID %s (%s) has completed, its task is gone\n", svcID, j.Name)
+		return nil
+	}
+
+	if j.Mode == "" {
+		exitCode := taskExitCode(&tasks[0])
+		ctx.Logger.Noticef("Service ID %s (%s) has completed with exit code %d\n", svcID, j.Name, exitCode)
+		return taskError(&tasks[0], exitCode)
+	}
+
+	// the result of every task is logged, the execution fails if any failed
+	var errs []error
+	for i := range tasks {
+		node := j.nodeName(tasks[i].NodeID)
+		exitCode := taskExitCode(&tasks[i])
+		ctx.Log(fmt.Sprintf("Task %s on node %s has completed with exit code %d", tasks[i].ID, node, exitCode))
+		if err := taskError(&tasks[i], exitCode); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", node, err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-func (j *RunServiceJob) findtaskstatus(ctx *Context, taskID string) (int, bool) {
-	taskFilters := make(map[string][]string)
-	taskFilters["service"] = []string{taskID}
+// taskWatch is what is known of the tasks of a service between two checks
+type taskWatch struct {
+	// seen is true once a task of the service was listed, the tasks are
+	// created asynchronously so the service may have none yet
+	seen bool
+	// count is the number of tasks of the previous check
+	count int
+}
 
+// findFinishedTasks returns the finished tasks of the service once all its
+// tasks are finished, no tasks are returned when they are gone
+func (j *RunServiceJob) findFinishedTasks(svcID string, watch *taskWatch) ([]swarm.Task, bool, error) {
 	tasks, err := j.Client.ListTasks(docker.ListTasksOptions{
-		Filters: taskFilters,
+		Filters: map[string][]string{"service": {svcID}},
 	})
 
 	if err != nil {
-		ctx.Logger.Errorf("Failed to find task ID %s. Considering the task terminated: %s\n", taskID, err.Error())
-		return 0, false
+		return nil, false, err
 	}
 
 	if len(tasks) == 0 {
-		// That task is gone now (maybe someone else removed it. Our work here is done
-		return 0, true
+		// once seen, the task is gone now (maybe someone else removed it),
+		// otherwise it is not created yet
+		return nil, watch.seen, nil
 	}
 
-	exitCode := 1
-	var done bool
-	stopStates := []swarm.TaskState{
-		swarm.TaskStateComplete,
-		swarm.TaskStateFailed,
-		swarm.TaskStateRejected,
-	}
+	previous := watch.count
+	watch.seen, watch.count = true, len(tasks)
 
+	var finished []swarm.Task
+	var failed bool
 	for _, task := range tasks {
+		switch task.Status.State {
+		case swarm.TaskStateComplete:
+			finished = append(finished, task)
+		case swarm.TaskStateFailed, swarm.TaskStateRejected:
+			finished = append(finished, task)
+			failed = true
+		}
+	}
 
-		stop := false
-		for _, stopState := range stopStates {
-			if task.Status.State == stopState {
-				stop = true
-				break
-			}
+	switch j.Mode {
+	case ServiceModeGlobalJob:
+		// the orchestrator creates the tasks of the nodes one by one, so they
+		// are all created once their number doesn't change between checks
+		return finished, len(finished) == len(tasks) && len(tasks) == previous, nil
+	case ServiceModeReplicatedJob:
+		// failed tasks are not restarted, so the job may never reach its
+		// completions
+		total := j.TotalCompletions
+		if total == 0 {
+			total = 1
 		}
 
-		if stop {
+		done := len(finished) == len(tasks) && (failed || uint64(len(finished)) >= total)
+		return finished, done, nil
+	default:
+		return finished, len(finished) != 0, nil
+	}
+}
 
-			exitCode = task.Status.ContainerStatus.ExitCode
+func taskExitCode(task *swarm.Task) int {
+	if task.Status.ContainerStatus == nil {
+		return 0
+	}
 
-			if exitCode == 0 && task.Status.State == swarm.TaskStateRejected {
-				exitCode = 255 // force non-zero exit for task rejected
-			}
-			done = true
-			break
-		}
+	return task.Status.ContainerStatus.ExitCode
+}
+
+// nodeName returns the hostname of the node, or its ID if it can't be found
+func (j *RunServiceJob) nodeName(id string) string {
+	node, err := j.Client.InspectNode(id)
+	if err != nil || node.Description.Hostname == "" {
+		return id
+	}
+
+	return node.Description.Hostname
+}
+
+// taskError returns the error of a finished task, including the error
+// message reported by swarm, eg.: "no suitable node"
+func taskError(task *swarm.Task, exitCode int) error {
+	var err error
+	switch {
+	case task.Status.State == swarm.TaskStateRejected:
+		err = errors.New("task rejected")
+	case exitCode != 0:
+		err = fmt.Errorf("error non-zero exit code: %d", exitCode)
+	case task.Status.State == swarm.TaskStateFailed:
+		err = errors.New("task failed")
+	default:
+		return nil
+	}
+
+	if task.Status.Err != "" {
+		return fmt.Errorf("%w: %s", err, task.Status.Err)
+	}
+
+	return err
+}
+
+// fetchLogs copies the logs of the service to the execution streams
+func (j *RunServiceJob) fetchLogs(ctx *Context, svcID string) {
+	err := j.Client.GetServiceLogs(docker.LogsServiceOptions{
+		Context:      ctx.Execution.Context(),
+		Service:      svcID,
+		OutputStream: ctx.Execution.OutputStream,
+		ErrorStream:  ctx.Execution.ErrorStream,
+		Stdout:       true,
+		Stderr:       true,
+		RawTerminal:  j.TTY,
+	})
+	if err != nil {
+		ctx.Warn("failed to fetch service logs: " + err.Error())
 	}
-	return exitCode, done
 }
 
 func (j *RunServiceJob) deleteService(ctx *Context, svcID string) error {
@@ -203,6 +529,10 @@ func (j *RunServiceJob) deleteService(ctx *Context, svcID string) error {
 		return nil
 	}
 
+	return j.removeService(ctx, svcID)
+}
+
+func (j *RunServiceJob) removeService(ctx *Context, svcID string) error {
 	err := j.Client.RemoveService(docker.RemoveServiceOptions{
 		ID: svcID,
 	})
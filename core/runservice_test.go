@@ -3,7 +3,11 @@ package core
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
@@ -69,6 +73,8 @@ func (s *SuiteRunServiceJob) TestRun(c *C) {
 		fmt.Printf("found tasks %v\n", tasks[0].Spec.ContainerSpec.Command)
 
 		c.Assert(strings.Join(tasks[0].Spec.ContainerSpec.Command, ","), Equals, "echo,-a,foo,bar")
+		c.Assert(tasks[0].Spec.ContainerSpec.User, Equals, "foo")
+		c.Assert(tasks[0].Spec.ContainerSpec.TTY, Equals, true)
 
 		c.Assert(tasks[0].Status.State, Equals, swarm.TaskStateReady)
 
@@ -82,7 +88,7 @@ func (s *SuiteRunServiceJob) TestRun(c *C) {
 
 	}()
 
-	err := job.Run(&Context{Execution: e, Logger: logger})
+	err := job.Run(&Context{Execution: e, Logger: logger, Job: job})
 	c.Assert(err, IsNil)
 	wg.Wait()
 
@@ -92,22 +98,342 @@ func (s *SuiteRunServiceJob) TestRun(c *C) {
 	c.Assert(containers, HasLen, 0)
 }
 
+func (s *SuiteRunServiceJob) TestRunFailedTask(c *C) {
+	s.server.CustomHandler("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]swarm.Task{{
+			Status: swarm.TaskStatus{
+				State:           swarm.TaskStateFailed,
+				Err:             "task: non-zero exit (2)",
+				ContainerStatus: &swarm.ContainerStatus{ExitCode: 2},
+			},
+		}})
+	}))
+	s.server.CustomHandler("/services/.*/logs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeFrame(w, 1, "dumping database\n")
+		writeFrame(w, 2, "connection refused\n")
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+	job.Image = ServiceImageFixture
+	job.Delete = "true"
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e, Logger: logger, Job: job})
+	c.Assert(err, ErrorMatches, `error non-zero exit code: 2: task: non-zero exit \(2\)`)
+	c.Assert(e.OutputStream.String(), Equals, "dumping database\n")
+	c.Assert(e.ErrorStream.String(), Equals, "connection refused\n")
+}
+
+func (s *SuiteRunServiceJob) TestRunListTasksError(c *C) {
+	var calls int
+	s.server.CustomHandler("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first calls fail, the task is not considered terminated
+		if calls++; calls < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode([]swarm.Task{{
+			Status: swarm.TaskStatus{
+				State:           swarm.TaskStateFailed,
+				ContainerStatus: &swarm.ContainerStatus{ExitCode: 3},
+			},
+		}})
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+	job.Image = ServiceImageFixture
+	job.Delete = "true"
+
+	err := job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `error non-zero exit code: 3.*`)
+	c.Assert(calls, Equals, 3)
+}
+
+func (s *SuiteRunServiceJob) TestRunTaskNotCreatedYet(c *C) {
+	var calls int
+	s.server.CustomHandler("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the task is created asynchronously, after the first check
+		if calls++; calls == 1 {
+			json.NewEncoder(w).Encode([]swarm.Task{})
+			return
+		}
+
+		json.NewEncoder(w).Encode([]swarm.Task{{
+			Status: swarm.TaskStatus{
+				State:           swarm.TaskStateFailed,
+				ContainerStatus: &swarm.ContainerStatus{ExitCode: 3},
+			},
+		}})
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+	job.Image = ServiceImageFixture
+	job.Delete = "true"
+
+	err := job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `error non-zero exit code: 3.*`)
+	c.Assert(calls, Equals, 2)
+}
+
+func (s *SuiteRunServiceJob) TestWatchContainerMaxRuntime(c *C) {
+	var calls int
+	s.server.CustomHandler("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		json.NewEncoder(w).Encode([]swarm.Task{{
+			Status: swarm.TaskStatus{State: swarm.TaskStateRunning},
+		}})
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+
+	start := time.Now()
+	err := job.watchContainer(newContext(job), "foo", time.Second)
+	c.Assert(err, Equals, ErrMaxTimeRunning)
+	c.Assert(time.Since(start) < 2*time.Second, Equals, true)
+
+	// checked at 0, 100ms, 300ms and 700ms, the interval doubles every time
+	c.Assert(calls, Equals, 4)
+}
+
+func (s *SuiteRunServiceJob) TestRunGlobalJob(c *C) {
+	s.server.CustomHandler("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]swarm.Task{{
+			ID:     "t1",
+			NodeID: "node-1",
+			Status: swarm.TaskStatus{
+				State:           swarm.TaskStateComplete,
+				ContainerStatus: &swarm.ContainerStatus{ExitCode: 0},
+			},
+		}, {
+			ID:     "t2",
+			NodeID: "node-2",
+			Status: swarm.TaskStatus{
+				State:           swarm.TaskStateFailed,
+				ContainerStatus: &swarm.ContainerStatus{ExitCode: 1},
+			},
+		}})
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "prune"
+	job.Image = ServiceImageFixture
+	job.Delete = "true"
+	job.Mode = ServiceModeGlobalJob
+
+	e := NewExecution()
+	err := job.Run(&Context{Execution: e, Logger: logger, Job: job})
+	c.Assert(err, ErrorMatches, `node node-2: error non-zero exit code: 1`)
+}
+
+func (s *SuiteRunServiceJob) TestRunGlobalJobTasksCreated(c *C) {
+	var calls int
+	s.server.CustomHandler("/tasks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tasks := []swarm.Task{{
+			ID:     "t1",
+			NodeID: "node-1",
+			Status: swarm.TaskStatus{State: swarm.TaskStateComplete},
+		}}
+
+		// the task of the second node is created after the first check
+		if calls++; calls > 1 {
+			tasks = append(tasks, swarm.Task{
+				ID:     "t2",
+				NodeID: "node-2",
+				Status: swarm.TaskStatus{
+					State:           swarm.TaskStateFailed,
+					ContainerStatus: &swarm.ContainerStatus{ExitCode: 1},
+				},
+			})
+		}
+
+		json.NewEncoder(w).Encode(tasks)
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "prune"
+	job.Image = ServiceImageFixture
+	job.Delete = "true"
+	job.Mode = ServiceModeGlobalJob
+
+	err := job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `node node-2: error non-zero exit code: 1`)
+}
+
+func (s *SuiteRunServiceJob) TestServiceMode(c *C) {
+	job := &RunServiceJob{}
+	mode, err := job.serviceMode()
+	c.Assert(err, IsNil)
+	c.Assert(mode, DeepEquals, swarm.ServiceMode{})
+
+	job.Mode = ServiceModeReplicatedJob
+	job.TotalCompletions = 4
+	job.MaxConcurrent = 2
+	mode, err = job.serviceMode()
+	c.Assert(err, IsNil)
+	c.Assert(*mode.ReplicatedJob.TotalCompletions, Equals, uint64(4))
+	c.Assert(*mode.ReplicatedJob.MaxConcurrent, Equals, uint64(2))
+
+	job.Mode = ServiceModeGlobalJob
+	_, err = job.serviceMode()
+	c.Assert(err, ErrorMatches, `total-completions and max-concurrent require .*`)
+
+	job.TotalCompletions, job.MaxConcurrent = 0, 0
+	mode, err = job.serviceMode()
+	c.Assert(err, IsNil)
+	c.Assert(mode.GlobalJob, NotNil)
+
+	job.Mode = "replicated"
+	_, err = job.serviceMode()
+	c.Assert(err, ErrorMatches, `unknown service mode "replicated"`)
+}
+
+func (s *SuiteRunServiceJob) TestRunMaxRuntime(c *C) {
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+	job.Image = ServiceImageFixture
+	job.Delete = "false"
+	job.MaxRuntime = "300ms"
+
+	err := job.Run(newContext(job))
+	c.Assert(err, Equals, ErrMaxTimeRunning)
+
+	// removed even if delete is false, its task would keep running
+	services, err := s.client.ListServices(docker.ListServicesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(services, HasLen, 0)
+
+	job.MaxRuntime = "foo"
+	err = job.Run(newContext(job))
+	c.Assert(err, ErrorMatches, `invalid max runtime "foo"`)
+}
+
+func (s *SuiteRunServiceJob) TestRunCanceled(c *C) {
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+	job.Image = ServiceImageFixture
+
+	e := NewExecution()
+	cause := fmt.Errorf("shutting down")
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		e.Cancel(cause)
+	}()
+
+	err := job.Run(&Context{Execution: e, Logger: logger, Job: job})
+	c.Assert(err, Equals, cause)
+
+	services, err := s.client.ListServices(docker.ListServicesOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(services, HasLen, 0)
+}
+
+func (s *SuiteRunServiceJob) TestTaskError(c *C) {
+	task := func(state swarm.TaskState, err string) *swarm.Task {
+		return &swarm.Task{Status: swarm.TaskStatus{State: state, Err: err}}
+	}
+
+	c.Assert(taskError(task(swarm.TaskStateComplete, ""), 0), IsNil)
+	c.Assert(taskError(task(swarm.TaskStateFailed, ""), 1), ErrorMatches, "error non-zero exit code: 1")
+	c.Assert(taskError(task(swarm.TaskStateFailed, "starting container failed"), 0), ErrorMatches, "task failed: starting container failed")
+	c.Assert(taskError(task(swarm.TaskStateRejected, "no suitable node"), 0), ErrorMatches, "task rejected: no suitable node")
+}
+
+// writeFrame writes a frame of a multiplexed stream, as the logs endpoints
+func writeFrame(w io.Writer, stream byte, content string) {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+	w.Write(header)
+	w.Write([]byte(content))
+}
+
+func (s *SuiteRunServiceJob) TestBuildServiceOptions(c *C) {
+	s.server.CustomHandler("/secrets", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]swarm.Secret{
+			{ID: "s1", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "db-password-old"}}},
+			{ID: "s2", Spec: swarm.SecretSpec{Annotations: swarm.Annotations{Name: "db-password"}}},
+		})
+	}))
+	s.server.CustomHandler("/configs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]swarm.Config{
+			{ID: "c1", Spec: swarm.ConfigSpec{Annotations: swarm.Annotations{Name: "backup.yml"}}},
+		})
+	}))
+
+	job := &RunServiceJob{Client: s.client}
+	job.Name = "backup"
+	job.SetType("job-service-run")
+	job.Image = ServiceImageFixture
+	job.Command = `sh -c "backup --all"`
+	job.User = "nobody"
+	job.TTY = true
+	job.Delete = "true"
+	job.Environment = []string{"FOO=bar"}
+	job.Mount = []string{"type=volume,source=backups,target=/backups"}
+	job.Secret = []string{"db-password"}
+	job.Config = []string{"source=backup.yml,target=/etc/backup.yml"}
+	job.Constraint = []string{"node.role==worker"}
+	job.Memory = "128m"
+	job.CPUs = "0.5"
+	job.Label = []string{"team=data"}
+
+	ctx := newContext(job)
+	opts, err := job.buildServiceOptions(ctx)
+	c.Assert(err, IsNil)
+
+	spec := opts.ServiceSpec.TaskTemplate.ContainerSpec
+	c.Assert(spec.Command, DeepEquals, []string{"sh", "-c", "backup --all"})
+	c.Assert(spec.User, Equals, "nobody")
+	c.Assert(spec.TTY, Equals, true)
+	c.Assert(spec.Env, DeepEquals, []string{
+		"OFELIA_JOB_TYPE=job-service-run",
+		"OFELIA_JOB_NAME=backup",
+		"OFELIA_EXECUTION_ID=" + ctx.Execution.ID,
+		"OFELIA_ATTEMPT=1",
+		"FOO=bar",
+	})
+	c.Assert(spec.Mounts, HasLen, 1)
+	c.Assert(spec.Mounts[0].Target, Equals, "/backups")
+	c.Assert(spec.Secrets, HasLen, 1)
+	c.Assert(spec.Secrets[0].SecretID, Equals, "s2")
+	c.Assert(spec.Secrets[0].File.Name, Equals, "db-password")
+	c.Assert(spec.Configs, HasLen, 1)
+	c.Assert(spec.Configs[0].ConfigID, Equals, "c1")
+	c.Assert(spec.Configs[0].File.Name, Equals, "/etc/backup.yml")
+	c.Assert(spec.Labels["team"], Equals, "data")
+	c.Assert(spec.Labels[LabelManaged], Equals, "true")
+	c.Assert(opts.ServiceSpec.Annotations.Labels["team"], Equals, "data")
+
+	task := opts.ServiceSpec.TaskTemplate
+	c.Assert(task.Placement.Constraints, DeepEquals, []string{"node.role==worker"})
+	c.Assert(task.Resources.Limits.MemoryBytes, Equals, int64(128*1024*1024))
+	c.Assert(task.Resources.Limits.NanoCPUs, Equals, int64(5e8))
+
+	job.Secret = []string{"missing"}
+	_, err = job.buildServiceOptions(ctx)
+	c.Assert(err, ErrorMatches, `secret "missing" not found`)
+}
+
 func (s *SuiteRunServiceJob) TestBuildPullImageOptionsBareImage(c *C) {
-	o, _ := buildPullOptions("foo")
+	o := buildPullOptions("foo")
 	c.Assert(o.Repository, Equals, "foo")
 	c.Assert(o.Tag, Equals, "latest")
 	c.Assert(o.Registry, Equals, "")
 }
 
 func (s *SuiteRunServiceJob) TestBuildPullImageOptionsVersion(c *C) {
-	o, _ := buildPullOptions("foo:qux")
+	o := buildPullOptions("foo:qux")
 	c.Assert(o.Repository, Equals, "foo")
 	c.Assert(o.Tag, Equals, "qux")
 	c.Assert(o.Registry, Equals, "")
 }
 
 func (s *SuiteRunServiceJob) TestBuildPullImageOptionsRegistry(c *C) {
-	o, _ := buildPullOptions("quay.io/srcd/rest:qux")
+	o := buildPullOptions("quay.io/srcd/rest:qux")
 	c.Assert(o.Repository, Equals, "quay.io/srcd/rest")
 	c.Assert(o.Tag, Equals, "qux")
 	c.Assert(o.Registry, Equals, "quay.io")
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// hashToken is the Jenkins-style token that can be used in any field of a
+// cron expression, it is replaced by a value picked from the job name, so
+// jobs sharing a schedule are spread along the range of the field.
+const hashToken = "H"
+
+type fieldBounds struct{ min, max int }
+
+var (
+	secondsBounds = fieldBounds{0, 59}
+	// the day of the month is limited to 28, so the job runs every month
+	scheduleBounds = []fieldBounds{{0, 59}, {0, 23}, {1, 28}, {1, 12}, {0, 6}}
+)
+
+// expandHashedSchedule replaces the H tokens of the given cron expression
+// with values derived from the given name. The supported forms are `H`,
+// `H(min-max)`, `H/step` and `H(min-max)/step`.
+func expandHashedSchedule(schedule, name string) (string, error) {
+	if !strings.Contains(schedule, hashToken) || strings.HasPrefix(schedule, "@") {
+		return schedule, nil
+	}
+
+	fields := strings.Fields(schedule)
+	bounds := scheduleBounds
+	if len(fields) == len(scheduleBounds)+1 {
+		bounds = append([]fieldBounds{secondsBounds}, bounds...)
+	}
+
+	if len(fields) != len(bounds) {
+		return "", fmt.Errorf("invalid schedule %q, expected 5 or 6 fields", schedule)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	for i, field := range fields {
+		var items []string
+		for _, item := range strings.Split(field, ",") {
+			expanded, err := expandHashedItem(item, bounds[i], r)
+			if err != nil {
+				return "", fmt.Errorf("invalid schedule %q: %w", schedule, err)
+			}
+
+			items = append(items, expanded)
+		}
+
+		fields[i] = strings.Join(items, ",")
+	}
+
+	return strings.Join(fields, " "), nil
+}
+
+func expandHashedItem(item string, b fieldBounds, r *rand.Rand) (string, error) {
+	if !strings.HasPrefix(item, hashToken) {
+		return item, nil
+	}
+
+	rest := strings.TrimPrefix(item, hashToken)
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end == -1 {
+			return "", fmt.Errorf("unclosed range in %q", item)
+		}
+
+		from, until, ok := strings.Cut(rest[1:end], "-")
+		if !ok {
+			return "", fmt.Errorf("invalid range in %q", item)
+		}
+
+		var err error
+		if b.min, err = strconv.Atoi(from); err != nil {
+			return "", fmt.Errorf("invalid range in %q", item)
+		}
+
+		if b.max, err = strconv.Atoi(until); err != nil || b.max < b.min {
+			return "", fmt.Errorf("invalid range in %q", item)
+		}
+
+		rest = rest[end+1:]
+	}
+
+	if rest == "" {
+		return strconv.Itoa(b.min + r.Intn(b.max-b.min+1)), nil
+	}
+
+	step, err := strconv.Atoi(strings.TrimPrefix(rest, "/"))
+	if !strings.HasPrefix(rest, "/") || err != nil || step < 1 {
+		return "", fmt.Errorf("invalid step in %q", item)
+	}
+
+	offset := b.min + r.Intn(min(step, b.max-b.min+1))
+	return fmt.Sprintf("%d-%d/%d", offset, b.max, step), nil
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+)
+
+type SuiteSchedule struct{}
+
+var _ = Suite(&SuiteSchedule{})
+
+func (s *SuiteSchedule) TestExpandHashedScheduleNoToken(c *C) {
+	for _, schedule := range []string{"@daily", "@every 1h", "0 1 * * *", "*/5 * * * * *"} {
+		expanded, err := expandHashedSchedule(schedule, "foo")
+		c.Assert(err, IsNil)
+		c.Assert(expanded, Equals, schedule)
+	}
+}
+
+func (s *SuiteSchedule) TestExpandHashedScheduleDeterministic(c *C) {
+	a, err := expandHashedSchedule("H H * * *", "foo")
+	c.Assert(err, IsNil)
+
+	b, err := expandHashedSchedule("H H * * *", "foo")
+	c.Assert(err, IsNil)
+	c.Assert(a, Equals, b)
+
+	sc := NewScheduler(&TestLogger{})
+	_, err = sc.cron.AddJob(a, &jobWrapper{s: sc})
+	c.Assert(err, IsNil)
+}
+
+func (s *SuiteSchedule) TestExpandHashedScheduleSpread(c *C) {
+	seen := map[string]bool{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		expanded, err := expandHashedSchedule("H H * * *", name)
+		c.Assert(err, IsNil)
+		seen[expanded] = true
+	}
+
+	c.Assert(len(seen) > 1, Equals, true)
+}
+
+func (s *SuiteSchedule) TestExpandHashedScheduleForms(c *C) {
+	expanded, err := expandHashedSchedule("H(0-29) H(2-4) H * H/2", "foo")
+	c.Assert(err, IsNil)
+
+	var minute, hour, dom, dowFrom, dowStep int
+	n, err := fmt.Sscanf(expanded, "%d %d %d * %d-6/%d", &minute, &hour, &dom, &dowFrom, &dowStep)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 5)
+	c.Assert(minute >= 0 && minute <= 29, Equals, true)
+	c.Assert(hour >= 2 && hour <= 4, Equals, true)
+	c.Assert(dom >= 1 && dom <= 28, Equals, true)
+	c.Assert(dowFrom >= 0 && dowFrom <= 1, Equals, true)
+	c.Assert(dowStep, Equals, 2)
+}
+
+func (s *SuiteSchedule) TestExpandHashedScheduleSeconds(c *C) {
+	expanded, err := expandHashedSchedule("H H * * * *", "foo")
+	c.Assert(err, IsNil)
+
+	var second, minute int
+	_, err = fmt.Sscanf(expanded, "%d %d * * * *", &second, &minute)
+	c.Assert(err, IsNil)
+	c.Assert(second >= 0 && second <= 59, Equals, true)
+}
+
+func (s *SuiteSchedule) TestExpandHashedScheduleInvalid(c *C) {
+	for _, schedule := range []string{"H * *", "H(5) * * * *", "H(5-1) * * * *", "H/0 * * * *", "Hx * * * *"} {
+		_, err := expandHashedSchedule(schedule, "foo")
+		c.Assert(err, NotNil, Commentf(schedule))
+	}
+}
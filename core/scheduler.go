@@ -3,7 +3,9 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
@@ -13,19 +15,43 @@ var (
 	ErrEmptySchedule  = errors.New("unable to add a job with a empty schedule")
 )
 
+const (
+	// onceSchedule and startupSchedule are the descriptors of the jobs that
+	// are only executed once, when the scheduler starts or, if it is already
+	// running, when the job is added.
+	onceSchedule    = "@once"
+	startupSchedule = "@startup"
+)
+
 type Scheduler struct {
 	Logger Logger
+	// InstanceID identifies the containers and services created by the jobs
+	// of this scheduler, see Reconciler
+	InstanceID string
 
 	middlewareContainer
-	cron      *cron.Cron
-	wg        sync.WaitGroup
-	isRunning bool
+	cron *cron.Cron
+	// runMu guards isRunning and the additions to wg, so Stop doesn't wait
+	// for executions starting meanwhile
+	runMu       sync.Mutex
+	wg          sync.WaitGroup
+	isRunning   bool
+	startupJobs []Job
+	// started are the names of the jobs already run on start, so a job
+	// replaced by a new version of it, eg.: when its labels change, doesn't
+	// run again
+	started     map[string]bool
+	startedMu   sync.Mutex
+	calendars   map[string]*Calendar
+	calendarsMu sync.RWMutex
+	stop        chan struct{}
 }
 
 func NewScheduler(l Logger) *Scheduler {
 	cronUtils := NewCronUtils(l)
 	return &Scheduler{
-		Logger: l,
+		Logger:     l,
+		InstanceID: DefaultInstanceID,
 		cron: cron.New(
 			cron.WithLogger(cronUtils),
 			cron.WithChain(cron.Recover(cronUtils)),
@@ -39,28 +65,126 @@ func NewScheduler(l Logger) *Scheduler {
 }
 
 func (s *Scheduler) AddJob(j Job) error {
-	if j.GetSchedule() == "" {
+	schedules := j.GetSchedules()
+	if len(schedules) == 0 {
 		return ErrEmptySchedule
 	}
 
-	id, err := s.cron.AddJob(j.GetSchedule(), &jobWrapper{s, j})
+	for _, schedule := range schedules {
+		if schedule == "" {
+			return ErrEmptySchedule
+		}
+	}
+
+	w, err := s.newJobWrapper(j)
 	if err != nil {
 		s.Logger.Warningf("Failed to register job %q - %q - %q. Error: %s", j.GetName(), j.GetCommand(), j.GetSchedule(), err)
 		return err
 	}
 
-	j.SetCronJobID(int(id))
+	// every schedule is a different cron entry, all of them sharing the same
+	// job, so the overlap state and the history are shared
+	var ids []int
+	runOnStart := j.ShouldRunOnStart()
+	for _, schedule := range schedules {
+		if isStartupSchedule(schedule) {
+			runOnStart = true
+			continue
+		}
+
+		id, err := s.addCronEntry(w, schedule)
+		if err != nil {
+			s.Logger.Warningf("Failed to register job %q - %q - %q. Error: %s", j.GetName(), j.GetCommand(), schedule, err)
+			s.removeCronEntries(ids)
+			return err
+		}
+
+		ids = append(ids, id)
+	}
+
+	j.SetCronJobIDs(ids)
 	j.Use(s.Middlewares()...)
-	s.Logger.Noticef("New job registered %q - %q - %q - ID: %v", j.GetName(), j.GetCommand(), j.GetSchedule(), id)
+	s.Logger.Noticef("New job registered %q - %q - %q - ID: %v", j.GetName(), j.GetCommand(), j.GetSchedule(), ids)
+
+	if runOnStart {
+		s.startupJobs = append(s.startupJobs, j)
+
+		// the scheduler is already running, so this is a job that was added
+		// later on (eg.: from docker labels), we run it right away, unless
+		// it already ran.
+		if s.IsRunning() && s.markStarted(j.GetName()) {
+			go s.runOnce(w)
+		}
+	}
+
 	return nil
 }
 
+func (s *Scheduler) addCronEntry(w *jobWrapper, schedule string) (int, error) {
+	expanded, err := expandHashedSchedule(schedule, w.j.GetName())
+	if err != nil {
+		return 0, err
+	}
+
+	if expanded != schedule {
+		s.Logger.Debugf("Job %q schedule %q expanded to %q", w.j.GetName(), schedule, expanded)
+	}
+
+	id, err := s.cron.AddJob(expanded, w)
+	return int(id), err
+}
+
+func (s *Scheduler) removeCronEntries(ids []int) {
+	for _, id := range ids {
+		s.cron.Remove(cron.EntryID(id))
+	}
+}
+
 func (s *Scheduler) RemoveJob(j Job) error {
-	s.Logger.Noticef("Job deregistered (will not fire again) %q - %q - %q - ID: %v", j.GetName(), j.GetCommand(), j.GetSchedule(), j.GetCronJobID())
-	s.cron.Remove(cron.EntryID(j.GetCronJobID()))
+	s.Logger.Noticef("Job deregistered (will not fire again) %q - %q - %q - ID: %v", j.GetName(), j.GetCommand(), j.GetSchedule(), j.GetCronJobIDs())
+	s.removeCronEntries(j.GetCronJobIDs())
+
+	for i, sj := range s.startupJobs {
+		if sj == j {
+			s.startupJobs = append(s.startupJobs[:i], s.startupJobs[i+1:]...)
+			break
+		}
+	}
+
 	return nil
 }
 
+// SetCalendar registers a calendar under the given name, replacing any
+// calendar previously registered with the same name.
+func (s *Scheduler) SetCalendar(name string, c *Calendar) {
+	s.calendarsMu.Lock()
+	defer s.calendarsMu.Unlock()
+
+	if s.calendars == nil {
+		s.calendars = make(map[string]*Calendar)
+	}
+
+	s.calendars[name] = c
+}
+
+// Calendar returns the calendar registered under the given name.
+func (s *Scheduler) Calendar(name string) (*Calendar, bool) {
+	s.calendarsMu.RLock()
+	defer s.calendarsMu.RUnlock()
+
+	c, ok := s.calendars[name]
+	return c, ok
+}
+
+// RemoveCalendar unregisters the calendar with the given name, the jobs
+// referencing it fail until it is registered again.
+func (s *Scheduler) RemoveCalendar(name string) {
+	s.calendarsMu.Lock()
+	defer s.calendarsMu.Unlock()
+
+	delete(s.calendars, name)
+}
+
 func (s *Scheduler) CronJobs() []cron.Entry {
 	return s.cron.Entries()
 }
@@ -68,33 +192,128 @@ func (s *Scheduler) CronJobs() []cron.Entry {
 func (s *Scheduler) Start() error {
 	s.Logger.Debugf("Starting scheduler with %d jobs", len(s.CronJobs()))
 
+	s.runMu.Lock()
 	s.isRunning = true
+	s.stop = make(chan struct{})
+	s.runMu.Unlock()
+
 	s.cron.Start()
+
+	for _, j := range s.startupJobs {
+		if !s.markStarted(j.GetName()) {
+			continue
+		}
+
+		w, _ := s.newJobWrapper(j)
+		go s.runOnce(w)
+	}
+
 	return nil
 }
 
 func (s *Scheduler) Stop() error {
+	s.runMu.Lock()
+	running := s.isRunning
+	s.isRunning = false
+	s.runMu.Unlock()
+
+	// jobs waiting for its jitter are not started
+	if running {
+		close(s.stop)
+	}
+
 	s.wg.Wait()
 	s.cron.Stop()
-	s.isRunning = false
 
 	return nil
 }
 
 func (s *Scheduler) IsRunning() bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
 	return s.isRunning
 }
 
+// enter registers an execution, waited for by Stop, it returns false if the
+// scheduler is stopped
+func (s *Scheduler) enter() bool {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	if !s.isRunning {
+		return false
+	}
+
+	s.wg.Add(1)
+	return true
+}
+
+// markStarted records that the job with the given name runs on start, it
+// returns false if it already did
+func (s *Scheduler) markStarted(name string) bool {
+	s.startedMu.Lock()
+	defer s.startedMu.Unlock()
+
+	if s.started[name] {
+		return false
+	}
+
+	if s.started == nil {
+		s.started = make(map[string]bool)
+	}
+
+	s.started[name] = true
+	return true
+}
+
+func (s *Scheduler) runOnce(w *jobWrapper) {
+	s.Logger.Debugf("Running job %q on start", w.j.GetName())
+	w.run(time.Now())
+}
+
+func (s *Scheduler) newJobWrapper(j Job) (*jobWrapper, error) {
+	w := &jobWrapper{s: s, j: j}
+	if j.GetJitter() == "" {
+		return w, nil
+	}
+
+	jitter, err := time.ParseDuration(j.GetJitter())
+	if err != nil {
+		return nil, fmt.Errorf("invalid jitter %q: %w", j.GetJitter(), err)
+	}
+
+	w.jitter = jitter
+	return w, nil
+}
+
+func isStartupSchedule(schedule string) bool {
+	return schedule == onceSchedule || schedule == startupSchedule
+}
+
 type jobWrapper struct {
-	s *Scheduler
-	j Job
+	s      *Scheduler
+	j      Job
+	jitter time.Duration
 }
 
 func (w *jobWrapper) Run() {
-	w.s.wg.Add(1)
+	w.run(w.scheduledTime())
+}
+
+func (w *jobWrapper) run(scheduled time.Time) {
+	if !w.s.enter() {
+		return
+	}
+
 	defer w.s.wg.Done()
 
+	if !w.wait() {
+		return
+	}
+
 	e := NewExecution()
+	e.ScheduledTime = scheduled
 	ctx := NewContext(w.s, w.j, e)
 
 	w.start(ctx)
@@ -102,6 +321,42 @@ func (w *jobWrapper) Run() {
 	w.stop(ctx, err)
 }
 
+// scheduledTime returns the time the job was scheduled for, the latest
+// activation of its cron entries. Cron replies to the lookup once it has
+// updated the entries that started the job, so that activation is found.
+func (w *jobWrapper) scheduledTime() time.Time {
+	var scheduled time.Time
+	for _, id := range w.j.GetCronJobIDs() {
+		if prev := w.s.cron.Entry(cron.EntryID(id)).Prev; prev.After(scheduled) {
+			scheduled = prev
+		}
+	}
+
+	if scheduled.IsZero() {
+		return time.Now()
+	}
+
+	return scheduled
+}
+
+// wait delays the execution a random amount of time, up to the job jitter,
+// it returns false if the scheduler was stopped meanwhile.
+func (w *jobWrapper) wait() bool {
+	if w.jitter <= 0 {
+		return true
+	}
+
+	delay := time.Duration(rand.Int63n(int64(w.jitter)))
+	w.s.Logger.Debugf("Job %q delayed %s by its jitter", w.j.GetName(), delay)
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-w.s.stop:
+		return false
+	}
+}
+
 func (w *jobWrapper) start(ctx *Context) {
 	ctx.Start()
 	ctx.Log("Started - " + ctx.Job.GetCommand())
@@ -12,7 +12,7 @@ var _ = Suite(&SuiteScheduler{})
 
 func (s *SuiteScheduler) TestAddJob(c *C) {
 	job := &TestJob{}
-	job.Schedule = "@hourly"
+	job.Schedule = []string{"@hourly"}
 
 	sc := NewScheduler(&TestLogger{})
 	err := sc.AddJob(job)
@@ -25,7 +25,7 @@ func (s *SuiteScheduler) TestAddJob(c *C) {
 
 func (s *SuiteScheduler) TestStartStop(c *C) {
 	job := &TestJob{}
-	job.Schedule = "@every 1s"
+	job.Schedule = []string{"@every 1s"}
 
 	sc := NewScheduler(&TestLogger{})
 	err := sc.AddJob(job)
@@ -40,11 +40,164 @@ func (s *SuiteScheduler) TestStartStop(c *C) {
 	c.Assert(sc.IsRunning(), Equals, false)
 }
 
+func (s *SuiteScheduler) TestAddJobMultipleSchedules(c *C) {
+	job := &TestJob{}
+	job.Schedule = []string{"0 8 * * 1-5", "0 11 * * 6,0"}
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+	c.Assert(job.GetCronJobIDs(), HasLen, 2)
+
+	e := sc.cron.Entries()
+	c.Assert(e, HasLen, 2)
+	c.Assert(e[0].Job, Equals, e[1].Job)
+
+	sc.RemoveJob(job)
+	c.Assert(sc.cron.Entries(), HasLen, 0)
+}
+
+func (s *SuiteScheduler) TestAddJobMultipleSchedulesInvalid(c *C) {
+	job := &TestJob{}
+	job.Schedule = []string{"@hourly", "foo"}
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, NotNil)
+	c.Assert(sc.cron.Entries(), HasLen, 0)
+}
+
+func (s *SuiteScheduler) TestAddJobEmptySchedule(c *C) {
+	sc := NewScheduler(&TestLogger{})
+	c.Assert(sc.AddJob(&TestJob{}), Equals, ErrEmptySchedule)
+
+	job := &TestJob{}
+	job.Schedule = []string{"@hourly", ""}
+	c.Assert(sc.AddJob(job), Equals, ErrEmptySchedule)
+}
+
+func (s *SuiteScheduler) TestAddJobOnce(c *C) {
+	job := newScheduledJob("@once")
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+	c.Assert(sc.cron.Entries(), HasLen, 0)
+
+	sc.Start()
+	job.waitExecution(c)
+
+	sc.Stop()
+	c.Assert(job.executions, HasLen, 0)
+}
+
+func (s *SuiteScheduler) TestAddJobRunOnStart(c *C) {
+	job := newScheduledJob("@hourly")
+	job.RunOnStart = true
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+	c.Assert(sc.cron.Entries(), HasLen, 1)
+
+	sc.Start()
+	job.waitExecution(c)
+
+	sc.Stop()
+	c.Assert(job.executions, HasLen, 0)
+}
+
+func (s *SuiteScheduler) TestAddJobStartupWhileRunning(c *C) {
+	sc := NewScheduler(&TestLogger{})
+	sc.Start()
+
+	job := newScheduledJob("@startup")
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+
+	job.waitExecution(c)
+
+	sc.Stop()
+	c.Assert(job.executions, HasLen, 0)
+}
+
+func (s *SuiteScheduler) TestAddJobStartupReplaced(c *C) {
+	sc := NewScheduler(&TestLogger{})
+	sc.Start()
+
+	job := newScheduledJob("@startup")
+	job.Name = "foo"
+	c.Assert(sc.AddJob(job), IsNil)
+	job.waitExecution(c)
+
+	// a new version of the job, eg.: after a label change, doesn't run again
+	sc.RemoveJob(job)
+	replaced := newScheduledJob("@startup")
+	replaced.Name = "foo"
+	c.Assert(sc.AddJob(replaced), IsNil)
+	replaced.assertNotExecuted(c)
+
+	sc.Stop()
+}
+
+func (s *SuiteScheduler) TestRemoveJobOnce(c *C) {
+	job := newScheduledJob("@once")
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+
+	sc.RemoveJob(job)
+	sc.Start()
+	job.assertNotExecuted(c)
+
+	sc.Stop()
+}
+
+func (s *SuiteScheduler) TestAddJobInvalidJitter(c *C) {
+	job := &TestJob{}
+	job.Schedule = []string{"@hourly"}
+	job.Jitter = "foo"
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, NotNil)
+	c.Assert(sc.cron.Entries(), HasLen, 0)
+}
+
+func (s *SuiteScheduler) TestAddJobHashedSchedule(c *C) {
+	job := &TestJob{}
+	job.Name = "foo"
+	job.Schedule = []string{"H H * * *"}
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+	c.Assert(sc.cron.Entries(), HasLen, 1)
+}
+
+func (s *SuiteScheduler) TestStopWhileJitter(c *C) {
+	job := newScheduledJob("@startup")
+	job.Jitter = "1h"
+
+	sc := NewScheduler(&TestLogger{})
+	err := sc.AddJob(job)
+	c.Assert(err, IsNil)
+
+	sc.Start()
+	time.Sleep(time.Millisecond * 100)
+
+	start := time.Now()
+	sc.Stop()
+	c.Assert(time.Since(start) < time.Second, Equals, true)
+	c.Assert(job.executions, HasLen, 0)
+}
+
 func (s *SuiteScheduler) TestMergeMiddlewaresSame(c *C) {
 	mA, mB, mC := &TestMiddleware{}, &TestMiddleware{}, &TestMiddleware{}
 
 	job := &TestJob{}
-	job.Schedule = "@every 1s"
+	job.Schedule = []string{"@every 1s"}
 	job.Use(mB, mC)
 
 	sc := NewScheduler(&TestLogger{})
@@ -68,6 +221,8 @@ func (s *SuiteScheduler) TestCronFormat(c *C) {
 		{"@weekly", false},
 		{"@monthly", false},
 		{"@yearly", false},
+		{"@once", false},
+		{"@startup", false},
 		{"* * * * *", false},
 		{"* * * * * *", false},
 		{"* * * * * * *", true},
@@ -75,7 +230,7 @@ func (s *SuiteScheduler) TestCronFormat(c *C) {
 
 	for _, tc := range testcases {
 		job := &TestJob{}
-		job.Schedule = tc.schedule
+		job.Schedule = []string{tc.schedule}
 
 		sc := NewScheduler(&TestLogger{})
 		err := sc.AddJob(job)
@@ -94,3 +249,55 @@ func (s *SuiteScheduler) TestCronFormat(c *C) {
 		c.Assert(sc.IsRunning(), Equals, false)
 	}
 }
+
+type scheduledJob struct {
+	BareJob
+	executions chan *Execution
+}
+
+func (j *scheduledJob) Run(ctx *Context) error {
+	j.executions <- ctx.Execution
+	return nil
+}
+
+func newScheduledJob(schedule ...string) *scheduledJob {
+	job := &scheduledJob{executions: make(chan *Execution, 10)}
+	job.Schedule = schedule
+	return job
+}
+
+// waitExecution waits for an execution of the job
+func (j *scheduledJob) waitExecution(c *C) *Execution {
+	select {
+	case e := <-j.executions:
+		return e
+	case <-time.After(time.Second):
+		c.Fatal("the job was not executed")
+		return nil
+	}
+}
+
+// assertNotExecuted checks that the job isn't executed for a while
+func (j *scheduledJob) assertNotExecuted(c *C) {
+	select {
+	case <-j.executions:
+		c.Fatal("the job was executed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *SuiteScheduler) TestScheduledTime(c *C) {
+	job := newScheduledJob("@every 1s")
+	job.Jitter = "300ms"
+
+	sc := NewScheduler(&TestLogger{})
+	c.Assert(sc.AddJob(job), IsNil)
+
+	sc.Start()
+	defer sc.Stop()
+
+	e := <-job.executions
+	c.Assert(e.ScheduledTime.IsZero(), Equals, false)
+	c.Assert(e.ScheduledTime.Nanosecond(), Equals, 0)
+	c.Assert(e.ScheduledTime.After(e.Date), Equals, false)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"bytes"
+	"io"
+)
+
+// lineLogger is a writer sending every complete line written to it to the
+// log of the execution, prefixed with the name of the stream
+type lineLogger struct {
+	ctx    *Context
+	stream string
+	buf    []byte
+}
+
+// maxLogLineLength is the longest line logged, the longer ones, eg.: progress
+// bars only rewriting the line with \r, are logged in pieces instead of
+// being kept in memory until the end of the line
+const maxLogLineLength = 16 * 1024
+
+func newLineLogger(ctx *Context, stream string) *lineLogger {
+	return &lineLogger{ctx: ctx, stream: stream}
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		switch {
+		case i != -1 && i <= maxLogLineLength:
+			l.log(l.buf[:i])
+			l.buf = l.buf[i+1:]
+		case len(l.buf) > maxLogLineLength:
+			l.log(l.buf[:maxLogLineLength])
+			l.buf = l.buf[maxLogLineLength:]
+		default:
+			return len(p), nil
+		}
+	}
+}
+
+// Flush logs the last line, if it was not terminated by a newline
+func (l *lineLogger) Flush() {
+	if len(l.buf) != 0 {
+		l.log(l.buf)
+		l.buf = nil
+	}
+}
+
+func (l *lineLogger) log(line []byte) {
+	l.ctx.Log(l.stream + ": " + string(bytes.TrimRight(line, "\r")))
+}
+
+// streamWriters returns the writers for the output and error streams of the
+// execution, if logging is set the lines are also written to the log
+func streamWriters(ctx *Context, logging bool) (stdout, stderr io.Writer, flush func()) {
+	if !logging {
+		return ctx.Execution.OutputStream, ctx.Execution.ErrorStream, func() {}
+	}
+
+	outLog, errLog := newLineLogger(ctx, "stdout"), newLineLogger(ctx, "stderr")
+	stdout = io.MultiWriter(ctx.Execution.OutputStream, outLog)
+	stderr = io.MultiWriter(ctx.Execution.ErrorStream, errLog)
+	return stdout, stderr, func() {
+		outLog.Flush()
+		errLog.Flush()
+	}
+}
+
+// prefixWriter writes the given prefix at the beginning of every line
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	// midLine is true when the last line written was not terminated
+	midLine bool
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		if !p.midLine {
+			buf.Write(p.prefix)
+		}
+
+		buf.Write(line)
+		p.midLine = line[len(line)-1] != '\n'
+	}
+
+	if _, err := p.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+
+	logging "github.com/op/go-logging"
+	. "gopkg.in/check.v1"
+)
+
+type SuiteStream struct{}
+
+var _ = Suite(&SuiteStream{})
+
+func (s *SuiteStream) TestStreamWriters(c *C) {
+	backend := logging.NewMemoryBackend(10)
+	logger := logging.MustGetLogger("stream")
+	logger.SetBackend(logging.AddModuleLevel(backend))
+
+	job := &LocalJob{}
+	job.Name = "foo"
+	ctx := &Context{Execution: NewExecution(), Job: job, Logger: logger}
+
+	stdout, stderr, flush := streamWriters(ctx, true)
+	stdout.Write([]byte("foo\nba"))
+	stdout.Write([]byte("r\r\nqux"))
+	stderr.Write([]byte("baz\n"))
+	flush()
+
+	c.Assert(ctx.Execution.OutputStream.String(), Equals, "foo\nbar\r\nqux")
+	c.Assert(ctx.Execution.ErrorStream.String(), Equals, "baz\n")
+
+	var lines []string
+	for n := backend.Head(); n != nil; n = n.Next() {
+		lines = append(lines, n.Record.Message())
+	}
+
+	c.Assert(lines, HasLen, 4)
+	c.Assert(lines[0], Matches, ".*stdout: foo$")
+	c.Assert(lines[1], Matches, ".*stdout: bar$")
+	c.Assert(lines[2], Matches, ".*stderr: baz$")
+	c.Assert(lines[3], Matches, ".*stdout: qux$")
+}
+
+func (s *SuiteStream) TestLineLoggerLongLine(c *C) {
+	backend := logging.NewMemoryBackend(10)
+	logger := logging.MustGetLogger("stream")
+	logger.SetBackend(logging.AddModuleLevel(backend))
+
+	job := &LocalJob{}
+	job.Name = "foo"
+	ctx := &Context{Execution: NewExecution(), Job: job, Logger: logger}
+
+	// a progress bar never ending the line isn't kept in memory
+	l := newLineLogger(ctx, "stdout")
+	for i := 0; i < maxLogLineLength; i++ {
+		l.Write([]byte("=\r"))
+	}
+
+	c.Assert(len(l.buf) <= maxLogLineLength, Equals, true)
+
+	var lines int
+	for n := backend.Head(); n != nil; n = n.Next() {
+		lines++
+	}
+
+	c.Assert(lines, Equals, 1)
+}
+
+func (s *SuiteStream) TestStreamWritersNoLogging(c *C) {
+	ctx := &Context{Execution: NewExecution()}
+
+	stdout, stderr, _ := streamWriters(ctx, false)
+	c.Assert(stdout, Equals, ctx.Execution.OutputStream)
+	c.Assert(stderr, Equals, ctx.Execution.ErrorStream)
+}
+
+func (s *SuiteStream) TestPrefixWriter(c *C) {
+	var buf bytes.Buffer
+	w := newPrefixWriter(&buf, "web-1: ")
+
+	fmt.Fprint(w, "foo\nba")
+	fmt.Fprint(w, "r\n\nqux")
+
+	c.Assert(buf.String(), Equals, "web-1: foo\nweb-1: bar\nweb-1: \nweb-1: qux")
+}
@@ -40,6 +40,14 @@ func (s *BaseSuite) SetUpTest(c *C) {
 	s.ctx = core.NewContext(sh, s.job, e)
 }
 
+// newContext returns the started context of a new execution of the suite job
+func (s *BaseSuite) newContext() *core.Context {
+	ctx := core.NewContext(s.ctx.Scheduler, s.job, core.NewExecution())
+	ctx.Start()
+
+	return ctx
+}
+
 type TestConfig struct {
 	Foo string
 	Qux int
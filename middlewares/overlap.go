@@ -1,26 +1,58 @@
 package middlewares
 
-import "github.com/mcuadros/ofelia/core"
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mcuadros/ofelia/core"
+)
+
+const (
+	// OverlapAllow runs every execution, even if the previous one is still running
+	OverlapAllow = "allow"
+	// OverlapSkip skips the new execution if the previous one is still running
+	OverlapSkip = "skip"
+	// OverlapQueue runs the new execution after the running one finishes
+	OverlapQueue = "queue"
+	// OverlapReplace cancels the running execution and starts the new one
+	OverlapReplace = "replace"
+	// OverlapKillPrevious is an alias of OverlapReplace
+	OverlapKillPrevious = "kill-previous"
+
+	defaultOverlapQueueSize = 1
+)
 
 // OverlapConfig configuration for the Overlap middleware
 type OverlapConfig struct {
-	NoOverlap bool `gcfg:"no-overlap" mapstructure:"no-overlap"`
+	NoOverlap        bool   `gcfg:"no-overlap" mapstructure:"no-overlap"`
+	OverlapPolicy    string `gcfg:"overlap-policy" mapstructure:"overlap-policy"`
+	OverlapQueueSize int    `gcfg:"overlap-queue-size" mapstructure:"overlap-queue-size"`
 }
 
 // NewOverlap returns a Overlap middleware if the given configuration is not empty
 func NewOverlap(c *OverlapConfig) core.Middleware {
 	var m core.Middleware
 	if !IsEmpty(c) {
-		m = &Overlap{*c}
+		m = &Overlap{OverlapConfig: *c, slot: make(chan struct{}, 1)}
 	}
 
 	return m
 }
 
-// Overlap when this middleware is enabled avoid to overlap executions from a
-// specific job
+// Overlap when this middleware is enabled decides what happens when a new
+// execution of a job starts while the previous one is still running
 type Overlap struct {
 	OverlapConfig
+
+	// slot is held by the running execution with the queue and replace
+	// policies, waiting is the number of executions waiting for it.
+	slot    chan struct{}
+	waiting int32
+
+	mu      sync.Mutex
+	current *core.Execution
+	latest  *core.Execution
 }
 
 // ContinueOnStop Overlap is only called if the process is still running
@@ -28,11 +60,93 @@ func (m *Overlap) ContinueOnStop() bool {
 	return false
 }
 
-// Run stops the execution if the another execution is already running
+// Run applies the overlap policy to the execution
 func (m *Overlap) Run(ctx *core.Context) error {
-	if m.NoOverlap && ctx.Job.Running() > 1 {
-		ctx.Stop(core.ErrSkippedExecution)
+	switch m.policy() {
+	case OverlapAllow:
+		return ctx.Next()
+	case OverlapSkip:
+		if ctx.Job.Running() > 1 {
+			ctx.Stop(core.ErrSkippedExecution)
+		}
+
+		return ctx.Next()
+	case OverlapQueue:
+		return m.runQueued(ctx)
+	case OverlapReplace, OverlapKillPrevious:
+		return m.runReplacing(ctx)
+	default:
+		return fmt.Errorf("unknown overlap policy %q", m.OverlapPolicy)
+	}
+}
+
+func (m *Overlap) policy() string {
+	if m.OverlapPolicy != "" {
+		return m.OverlapPolicy
+	}
+
+	if m.NoOverlap {
+		return OverlapSkip
+	}
+
+	return OverlapAllow
+}
+
+func (m *Overlap) queueSize() int {
+	if m.OverlapQueueSize > 0 {
+		return m.OverlapQueueSize
 	}
 
+	return defaultOverlapQueueSize
+}
+
+func (m *Overlap) runQueued(ctx *core.Context) error {
+	select {
+	case m.slot <- struct{}{}:
+	default:
+		if int(atomic.AddInt32(&m.waiting, 1)) > m.queueSize() {
+			atomic.AddInt32(&m.waiting, -1)
+			ctx.Stop(fmt.Errorf("%w: the overlap queue is full", core.ErrSkippedExecution))
+			return ctx.Next()
+		}
+
+		ctx.Log("Queued until the previous execution finishes")
+		m.slot <- struct{}{}
+		atomic.AddInt32(&m.waiting, -1)
+	}
+
+	defer func() { <-m.slot }()
 	return ctx.Next()
 }
+
+func (m *Overlap) runReplacing(ctx *core.Context) error {
+	m.mu.Lock()
+	m.latest = ctx.Execution
+	if m.current != nil {
+		ctx.Log("Canceling previous execution " + m.current.ID)
+		m.current.Cancel(fmt.Errorf("%w: replaced by execution %s", core.ErrSkippedExecution, ctx.Execution.ID))
+	}
+	m.mu.Unlock()
+
+	m.slot <- struct{}{}
+	defer func() { <-m.slot }()
+
+	// another execution could start while this one was waiting
+	m.mu.Lock()
+	if latest := m.latest; latest != ctx.Execution {
+		m.mu.Unlock()
+		ctx.Stop(fmt.Errorf("%w: replaced by execution %s", core.ErrSkippedExecution, latest.ID))
+		return ctx.Next()
+	}
+
+	m.current = ctx.Execution
+	m.mu.Unlock()
+
+	err := ctx.Next()
+
+	m.mu.Lock()
+	m.current = nil
+	m.mu.Unlock()
+
+	return err
+}
@@ -1,6 +1,13 @@
 package middlewares
 
-import . "gopkg.in/check.v1"
+import (
+	"errors"
+	"time"
+
+	"github.com/mcuadros/ofelia/core"
+
+	. "gopkg.in/check.v1"
+)
 
 type SuiteOverlap struct {
 	BaseSuite
@@ -27,3 +34,80 @@ func (s *SuiteOverlap) TestRunOverlap(c *C) {
 	c.Assert(s.ctx.Execution.IsRunning, Equals, false)
 	c.Assert(s.ctx.Execution.Skipped, Equals, true)
 }
+
+func (s *SuiteOverlap) TestRunOverlapPolicySkip(c *C) {
+	s.ctx.Start()
+	s.ctx.Job.NotifyStart()
+
+	m := NewOverlap(&OverlapConfig{OverlapPolicy: OverlapSkip})
+	c.Assert(m.Run(s.ctx), IsNil)
+	c.Assert(s.ctx.Execution.Skipped, Equals, true)
+}
+
+func (s *SuiteOverlap) TestRunOverlapPolicyAllow(c *C) {
+	s.ctx.Start()
+	s.ctx.Job.NotifyStart()
+
+	m := NewOverlap(&OverlapConfig{NoOverlap: true, OverlapPolicy: OverlapAllow})
+	c.Assert(m.Run(s.ctx), IsNil)
+	c.Assert(s.ctx.Execution.Skipped, Equals, false)
+}
+
+func (s *SuiteOverlap) TestRunOverlapPolicyUnknown(c *C) {
+	s.ctx.Start()
+
+	m := NewOverlap(&OverlapConfig{OverlapPolicy: "foo"})
+	c.Assert(m.Run(s.ctx), ErrorMatches, `unknown overlap policy "foo"`)
+}
+
+func (s *SuiteOverlap) TestRunOverlapPolicyQueue(c *C) {
+	m := NewOverlap(&OverlapConfig{OverlapPolicy: OverlapQueue}).(*Overlap)
+	// an execution is already running
+	m.slot <- struct{}{}
+
+	queued := s.newContext()
+	done := make(chan error)
+	go func() { done <- m.Run(queued) }()
+
+	time.Sleep(50 * time.Millisecond)
+	c.Assert(queued.Execution.IsRunning, Equals, true)
+
+	// the queue is full
+	skipped := s.newContext()
+	c.Assert(m.Run(skipped), IsNil)
+	c.Assert(skipped.Execution.Skipped, Equals, true)
+
+	<-m.slot
+	c.Assert(<-done, IsNil)
+	c.Assert(queued.Execution.Skipped, Equals, false)
+	c.Assert(queued.Execution.IsRunning, Equals, false)
+}
+
+func (s *SuiteOverlap) TestRunOverlapPolicyReplace(c *C) {
+	m := NewOverlap(&OverlapConfig{OverlapPolicy: OverlapReplace}).(*Overlap)
+
+	previous := s.newContext()
+	m.current = previous.Execution
+	m.slot <- struct{}{}
+
+	ctx := s.newContext()
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	select {
+	case <-previous.Execution.Context().Done():
+	case <-time.After(time.Second):
+		c.Fatal("previous execution was not canceled")
+	}
+
+	cause := previous.Execution.Canceled()
+	c.Assert(errors.Is(cause, core.ErrSkippedExecution), Equals, true)
+
+	// the previous execution finishes
+	m.current = nil
+	<-m.slot
+
+	c.Assert(<-done, IsNil)
+	c.Assert(ctx.Execution.Skipped, Equals, false)
+	c.Assert(m.current, IsNil)
+}
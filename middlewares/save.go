@@ -1,10 +1,15 @@
 package middlewares
 
 import (
+	"archive/tar"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/mcuadros/ofelia/core"
 )
@@ -73,9 +78,57 @@ func (m *Save) saveToDisk(ctx *core.Context) error {
 		return err
 	}
 
+	for _, a := range e.Artifacts {
+		if err := m.saveArtifact(a, fmt.Sprintf("%s.artifacts", root)); err != nil {
+			return fmt.Errorf("error saving artifact %q: %w", a.Path, err)
+		}
+	}
+
 	return nil
 }
 
+// saveArtifact extracts the archive of the artifact into the given folder,
+// only regular files and directories are extracted
+func (m *Save) saveArtifact(a core.Artifact, folder string) error {
+	tr := tar.NewReader(bytes.NewReader(a.Archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path %q in archive", hdr.Name)
+		}
+
+		filename := filepath.Join(folder, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(filename, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+				return err
+			}
+
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+
+			if err := m.writeFile(data, filename); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 func (m *Save) saveContextToDisk(ctx *core.Context, filename string) error {
 	js, _ := json.MarshalIndent(map[string]interface{}{
 		"Job":       ctx.Job,
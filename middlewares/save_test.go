@@ -1,6 +1,8 @@
 package middlewares
 
 import (
+	"archive/tar"
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -123,3 +125,55 @@ func (s *SuiteSave) TestSensitiveData(c *C) {
 		}
 	}
 }
+
+func (s *SuiteSave) TestRunArtifacts(c *C) {
+	dir, err := ioutil.TempDir("/tmp", "save")
+	c.Assert(err, IsNil)
+
+	s.ctx.Start()
+	s.ctx.Stop(nil)
+
+	s.job.Name = "foo"
+	s.ctx.Execution.Date = time.Time{}
+	s.ctx.Execution.Artifacts = []core.Artifact{{
+		Path:    "/app/out",
+		Archive: buildArchive(c, map[string]string{"out/": "", "out/report.csv": "foo,bar"}),
+	}}
+
+	m := NewSave(&SaveConfig{SaveFolder: dir})
+	c.Assert(m.Run(s.ctx), IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "00010101_000000_foo.artifacts", "out", "report.csv"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo,bar")
+}
+
+func (s *SuiteSave) TestSaveArtifactInvalidPath(c *C) {
+	dir, err := ioutil.TempDir("/tmp", "save")
+	c.Assert(err, IsNil)
+
+	a := core.Artifact{Archive: buildArchive(c, map[string]string{"../evil": "foo"})}
+	err = (&Save{}).saveArtifact(a, filepath.Join(dir, "artifacts"))
+	c.Assert(err, NotNil)
+
+	_, err = os.Stat(filepath.Join(dir, "evil"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func buildArchive(c *C, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+
+		c.Assert(tw.WriteHeader(hdr), IsNil)
+		_, err := tw.Write([]byte(content))
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(tw.Close(), IsNil)
+	return buf.Bytes()
+}
@@ -85,10 +85,16 @@ func (m *Slack) buildMessage(ctx *core.Context) *slackMessage {
 			Color: "#F35A00",
 		})
 	} else if ctx.Execution.Skipped {
-		msg.Attachments = append(msg.Attachments, slackAttachment{
+		attachment := slackAttachment{
 			Title: "Execution skipped",
 			Color: "#FFA500",
-		})
+		}
+
+		if ctx.Execution.Error != nil {
+			attachment.Text = ctx.Execution.Error.Error()
+		}
+
+		msg.Attachments = append(msg.Attachments, attachment)
 	} else {
 		msg.Attachments = append(msg.Attachments, slackAttachment{
 			Title: "Execution successful",
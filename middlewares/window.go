@@ -0,0 +1,131 @@
+package middlewares
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mcuadros/ofelia/core"
+)
+
+var windowTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+const windowDateFormat = "2006-01-02"
+
+// WindowConfig configuration for the Window middleware
+type WindowConfig struct {
+	ActiveFrom  string   `gcfg:"active-from" mapstructure:"active-from"`
+	ActiveUntil string   `gcfg:"active-until" mapstructure:"active-until"`
+	Blackout    []string `gcfg:"blackout" mapstructure:"blackout"`
+}
+
+// NewWindow returns a Window middleware if the given configuration is not empty
+func NewWindow(c *WindowConfig) core.Middleware {
+	var m core.Middleware
+	if !IsEmpty(c) {
+		m = &Window{*c}
+	}
+
+	return m
+}
+
+// Window skips the executions of a job happening outside of its validity
+// period or inside of any of the blackout calendars it references
+type Window struct {
+	WindowConfig
+}
+
+// ContinueOnStop Window is only called if the process is still running
+func (m *Window) ContinueOnStop() bool {
+	return false
+}
+
+// Run stops the execution as skipped, recording the reason, if it falls
+// outside the window
+func (m *Window) Run(ctx *core.Context) error {
+	reason, err := m.skipReason(ctx)
+	if err != nil {
+		return err
+	}
+
+	if reason != "" {
+		ctx.Stop(fmt.Errorf("%w: %s", core.ErrSkippedExecution, reason))
+	}
+
+	return ctx.Next()
+}
+
+func (m *Window) skipReason(ctx *core.Context) (string, error) {
+	t := ctx.Execution.Date
+
+	if m.ActiveFrom != "" {
+		from, err := parseWindowTime(m.ActiveFrom, t.Location(), false)
+		if err != nil {
+			return "", err
+		}
+
+		if t.Before(from) {
+			return fmt.Sprintf("job is not active until %s", m.ActiveFrom), nil
+		}
+	}
+
+	if m.ActiveUntil != "" {
+		until, err := parseWindowTime(m.ActiveUntil, t.Location(), true)
+		if err != nil {
+			return "", err
+		}
+
+		if !t.Before(until) {
+			return fmt.Sprintf("job is not active since %s", m.ActiveUntil), nil
+		}
+	}
+
+	for _, name := range m.Blackout {
+		name = strings.TrimSpace(name)
+
+		var cal *core.Calendar
+		var ok bool
+		if ctx.Scheduler != nil {
+			cal, ok = ctx.Scheduler.Calendar(name)
+		}
+
+		if !ok {
+			return "", fmt.Errorf("unknown blackout calendar %q", name)
+		}
+
+		entry, err := cal.Contains(t)
+		if err != nil {
+			return "", err
+		}
+
+		if entry != "" {
+			return fmt.Sprintf("blackout calendar %q, %s", name, entry), nil
+		}
+	}
+
+	return "", nil
+}
+
+// parseWindowTime parses a date or a date and time, when only a date is
+// given and endOfDay is true, the returned time is the end of that day.
+func parseWindowTime(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation(windowDateFormat, value, loc); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+
+		return t, nil
+	}
+
+	for _, format := range windowTimeFormats {
+		if t, err := time.ParseInLocation(format, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q, expected format is %s or %s", value, windowDateFormat, time.RFC3339)
+}
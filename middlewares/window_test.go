@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mcuadros/ofelia/core"
+
+	. "gopkg.in/check.v1"
+)
+
+type SuiteWindow struct {
+	BaseSuite
+}
+
+var _ = Suite(&SuiteWindow{})
+
+func (s *SuiteWindow) TestNewWindowEmpty(c *C) {
+	c.Assert(NewWindow(&WindowConfig{}), IsNil)
+}
+
+func (s *SuiteWindow) TestRunActive(c *C) {
+	s.ctx.Start()
+
+	m := NewWindow(&WindowConfig{ActiveFrom: "2000-01-01", ActiveUntil: "2999-12-31"})
+	c.Assert(m.Run(s.ctx), IsNil)
+	c.Assert(s.ctx.Execution.Skipped, Equals, false)
+}
+
+func (s *SuiteWindow) TestRunNotActiveYet(c *C) {
+	s.ctx.Start()
+
+	m := NewWindow(&WindowConfig{ActiveFrom: "2999-01-01"})
+	c.Assert(m.Run(s.ctx), IsNil)
+	c.Assert(s.ctx.Execution.IsRunning, Equals, false)
+	c.Assert(s.ctx.Execution.Skipped, Equals, true)
+	c.Assert(errors.Is(s.ctx.Execution.Error, core.ErrSkippedExecution), Equals, true)
+}
+
+func (s *SuiteWindow) TestRunExpired(c *C) {
+	s.ctx.Start()
+
+	m := NewWindow(&WindowConfig{ActiveUntil: time.Now().Add(-time.Minute).Format(time.RFC3339)})
+	c.Assert(m.Run(s.ctx), IsNil)
+	c.Assert(s.ctx.Execution.Skipped, Equals, true)
+}
+
+func (s *SuiteWindow) TestRunBlackout(c *C) {
+	s.ctx.Start()
+	s.ctx.Scheduler.SetCalendar("always", &core.Calendar{Window: []string{"mon-sun"}})
+
+	m := NewWindow(&WindowConfig{Blackout: []string{"always"}})
+	c.Assert(m.Run(s.ctx), IsNil)
+	c.Assert(s.ctx.Execution.Skipped, Equals, true)
+	c.Assert(s.ctx.Execution.Error, ErrorMatches, `skipped execution: blackout calendar "always", window mon-sun`)
+}
+
+func (s *SuiteWindow) TestRunUnknownCalendar(c *C) {
+	s.ctx.Start()
+
+	m := NewWindow(&WindowConfig{Blackout: []string{"foo"}})
+	c.Assert(m.Run(s.ctx), ErrorMatches, `unknown blackout calendar "foo"`)
+}